@@ -8,17 +8,33 @@ import (
 )
 
 type Config struct {
-	ServerConfig    ServerConfig    `mapstructure:"server"`
-	RedisConfig     RedisConfig     `mapstructure:"redis"`
-	DBConfig        DBConfig        `mapstructure:"database"`
-	AuthTokenConfig AuthTokenConfig `mapstructure:"auth-token"`
-	Roles           []string        `mapstructure:"roles"`
+	ServerConfig         ServerConfig         `mapstructure:"server"`
+	RedisConfig          RedisConfig          `mapstructure:"redis"`
+	DBConfig             DBConfig             `mapstructure:"database"`
+	AuthTokenConfig      AuthTokenConfig      `mapstructure:"auth-token"`
+	PasswordResetConfig  PasswordResetConfig  `mapstructure:"password-reset"`
+	EmailVerifyConfig    EmailVerifyConfig    `mapstructure:"email-verification"`
+	RegistrationConfig   RegistrationConfig   `mapstructure:"registration"`
+	PasswordPolicyConfig PasswordPolicyConfig `mapstructure:"password-policy"`
+	PasswordHashConfig   PasswordHashConfig   `mapstructure:"password-hash"`
+	LoginThrottleConfig  LoginThrottleConfig  `mapstructure:"login-throttle"`
+	MFAConfig            MFAConfig            `mapstructure:"mfa"`
+	MailConfig           MailConfig           `mapstructure:"mail"`
+	DeletedUsersConfig   DeletedUsersConfig   `mapstructure:"deleted-users"`
+	Roles                []RoleConfig         `mapstructure:"roles"`
+	RoleCacheTTL         time.Duration        `mapstructure:"role-cache-ttl"`
+}
+
+type RoleConfig struct {
+	Name        string   `mapstructure:"name"`
+	Permissions []string `mapstructure:"permissions"`
 }
 
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
-	Env  string `mapstructure:"env"`
+	Host              string `mapstructure:"host"`
+	Port              int    `mapstructure:"port"`
+	Env               string `mapstructure:"env"`
+	TrustProxyHeaders bool   `mapstructure:"trust-proxy-headers"`
 }
 
 type RedisConfig struct {
@@ -37,8 +53,116 @@ type DBConfig struct {
 }
 
 type AuthTokenConfig struct {
-	Duration  time.Duration `mapstructure:"duration"`
-	SecretKey string        `mapstructure:"secretkey"`
+	Duration        time.Duration      `mapstructure:"duration"`
+	RefreshDuration time.Duration      `mapstructure:"refresh-duration"`
+	SecretKey       string             `mapstructure:"secretkey"`
+	SigningKeyID    string             `mapstructure:"signing-key-id"`
+	Keys            []SigningKeyConfig `mapstructure:"keys"`
+}
+
+type SigningKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"`
+	PrivateKeyFile string `mapstructure:"private-key-file"`
+	PublicKeyFile  string `mapstructure:"public-key-file"`
+}
+
+type PasswordResetConfig struct {
+	TTL time.Duration `mapstructure:"ttl"`
+	URL string        `mapstructure:"url"`
+}
+
+type EmailVerifyConfig struct {
+	Required bool          `mapstructure:"required"`
+	TTL      time.Duration `mapstructure:"ttl"`
+	URL      string        `mapstructure:"url"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength            int    `mapstructure:"min-length"`
+	MaxLength            int    `mapstructure:"max-length"`
+	RequireUpper         bool   `mapstructure:"require-upper"`
+	RequireLower         bool   `mapstructure:"require-lower"`
+	RequireDigit         bool   `mapstructure:"require-digit"`
+	RequireSymbol        bool   `mapstructure:"require-symbol"`
+	DisallowUserInfo     bool   `mapstructure:"disallow-user-info"`
+	BreachedPrefixDir    string `mapstructure:"breached-prefix-dir"`
+	CommonPasswordsFile  string `mapstructure:"common-passwords-file"`
+	CommonPasswordsLimit int    `mapstructure:"common-passwords-limit"`
+}
+
+type PasswordHashConfig struct {
+	Algorithm string       `mapstructure:"algorithm"`
+	Argon2id  Argon2Config `mapstructure:"argon2id"`
+	Bcrypt    BcryptConfig `mapstructure:"bcrypt"`
+}
+
+type Argon2Config struct {
+	Memory      uint32 `mapstructure:"memory"`
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+	SaltLength  uint32 `mapstructure:"salt-length"`
+	KeyLength   uint32 `mapstructure:"key-length"`
+}
+
+type BcryptConfig struct {
+	Cost int `mapstructure:"cost"`
+}
+
+type LoginThrottleConfig struct {
+	Window           time.Duration `mapstructure:"window"`
+	FreeAttempts     int64         `mapstructure:"free-attempts"`
+	IPFreeAttempts   int64         `mapstructure:"ip-free-attempts"`
+	BaseDelay        time.Duration `mapstructure:"base-delay"`
+	MaxDelay         time.Duration `mapstructure:"max-delay"`
+	LockoutThreshold int64         `mapstructure:"lockout-threshold"`
+	LockoutDuration  time.Duration `mapstructure:"lockout-duration"`
+}
+
+type MFAConfig struct {
+	Issuer        string        `mapstructure:"issuer"`
+	EncryptionKey string        `mapstructure:"encryption-key"`
+	ChallengeTTL  time.Duration `mapstructure:"challenge-ttl"`
+	MaxAttempts   int64         `mapstructure:"max-attempts"`
+	RecoveryCodes int           `mapstructure:"recovery-codes"`
+	RequiredRoles []string      `mapstructure:"required-roles"`
+}
+
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite-only"
+	RegistrationDisabled   = "disabled"
+)
+
+type RegistrationConfig struct {
+	Mode        string        `mapstructure:"mode"`
+	DefaultRole string        `mapstructure:"default-role"`
+	InviteTTL   time.Duration `mapstructure:"invite-ttl"`
+	InviteURL   string        `mapstructure:"invite-url"`
+}
+
+type MailConfig struct {
+	Transport     string        `mapstructure:"transport"`
+	From          string        `mapstructure:"from"`
+	DefaultLocale string        `mapstructure:"default-locale"`
+	Dir           string        `mapstructure:"dir"`
+	SMTP          SMTPConfig    `mapstructure:"smtp"`
+	Workers       int           `mapstructure:"workers"`
+	QueueSize     int           `mapstructure:"queue-size"`
+	MaxAttempts   int           `mapstructure:"max-attempts"`
+	RetryBackoff  time.Duration `mapstructure:"retry-backoff"`
+}
+
+type DeletedUsersConfig struct {
+	Retention     time.Duration `mapstructure:"retention"`
+	PurgeInterval time.Duration `mapstructure:"purge-interval"`
+}
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
 }
 
 func GetConfig() *Config {
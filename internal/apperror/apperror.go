@@ -0,0 +1,90 @@
+package apperror
+
+import (
+	"errors"
+)
+
+// Code is the machine readable error code clients get in extensions.code
+type Code string
+
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeUnauthenticated  Code = "UNAUTHENTICATED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeValidationFailed Code = "VALIDATION_FAILED"
+	CodeConflict         Code = "CONFLICT"
+	CodeInternal         Code = "INTERNAL"
+)
+
+// Error is an error that is safe to show to clients. The wrapped Err is only for logs.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Extensions implements gqlerrors.ExtendedError so graphql-go adds the code to the response
+func (e *Error) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	for k, v := range e.Details {
+		ext[k] = v
+	}
+	return ext
+}
+
+// WithDetail adds an extra key to the error's extensions
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+func Unauthenticated(message string) *Error {
+	return New(CodeUnauthenticated, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, message)
+}
+
+func Validation(message string) *Error {
+	return New(CodeValidationFailed, message)
+}
+
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+// Internal hides err behind a generic message
+func Internal(err error) *Error {
+	return &Error{Code: CodeInternal, Message: "internal error", Err: err}
+}
+
+// CodeOf returns the code of err, errors that aren't an *Error are internal
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,23 @@
+package audit
+
+import "context"
+
+// RequestInfo is what the audit trail records about the request behind an action
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type requestInfoKey struct{}
+
+// NewContext returns a context carrying the request info
+func NewContext(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// FromContext returns the request info of ctx, it is empty outside of a request
+func FromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
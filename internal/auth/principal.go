@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Methods a principal can have signed in with
+const (
+	MethodPassword = "pwd"
+	MethodMFA      = "mfa"
+)
+
+// AMR returns the RFC 8176 method references of a sign-in method
+func AMR(method string) []string {
+	if method == MethodMFA {
+		return []string{"pwd", "otp", "mfa"}
+	}
+	return []string{"pwd"}
+}
+
+// MethodFromAMR returns the sign-in method behind the method references of a token, tokens
+// issued before methods were recorded all came from a password
+func MethodFromAMR(amr []string) string {
+	for _, m := range amr {
+		if m == "mfa" {
+			return MethodMFA
+		}
+	}
+	return MethodPassword
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID      string
+	Role        string
+	Groups      []string
+	Permissions []string
+	TenantID    string
+	TokenID     string
+	AuthMethod  string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// HasPermission reports whether the role or a group of the principal grants the permission
+func (p *Principal) HasPermission(permission string) bool {
+	if p == nil {
+		return false
+	}
+	for _, perm := range p.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, ok is false for anonymous requests
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
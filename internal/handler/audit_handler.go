@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+var auditChangeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditChange",
+	Fields: graphql.Fields{
+		"field": &graphql.Field{Type: graphql.String},
+		// JSON encoded, the values have the type of the field they belong to
+		"before": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return auditValue(p.Source, true)
+			},
+		},
+		"after": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return auditValue(p.Source, false)
+			},
+		},
+	},
+})
+
+var auditEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEvent",
+	Fields: graphql.Fields{
+		"id":             &graphql.Field{Type: graphql.String},
+		"organizationId": auditEventField(func(e model.AuditEvent) string { return e.TenantID }),
+		"actorId":        auditEventField(func(e model.AuditEvent) string { return e.ActorID }),
+		"action":         &graphql.Field{Type: graphql.String},
+		"targetId":       auditEventField(func(e model.AuditEvent) string { return e.TargetID }),
+		"changes":        &graphql.Field{Type: graphql.NewList(auditChangeType)},
+		"ip":             &graphql.Field{Type: graphql.String},
+		"userAgent":      auditEventField(func(e model.AuditEvent) string { return e.UserAgent }),
+		"requestId":      auditEventField(func(e model.AuditEvent) string { return e.RequestID }),
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if event, ok := p.Source.(model.AuditEvent); ok {
+					return event.CreatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var auditEventEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEventEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: auditEventType},
+	},
+})
+
+var auditEventConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEventConnection",
+	Fields: graphql.Fields{
+		"edges":    &graphql.Field{Type: graphql.NewList(auditEventEdgeType)},
+		"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+var auditFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "AuditFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"actorId":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"action":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"targetId": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"since":    &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"until":    &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+	},
+})
+
+// auditEventField resolves a string of the event, empty strings are null
+func auditEventField(get func(model.AuditEvent) string) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.String,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if event, ok := p.Source.(model.AuditEvent); ok && get(event) != "" {
+				return get(event), nil
+			}
+			return nil, nil
+		},
+	}
+}
+
+func auditValue(source interface{}, before bool) (interface{}, error) {
+	change, ok := source.(model.AuditChange)
+	if !ok {
+		return nil, nil
+	}
+	v := change.After
+	if before {
+		v = change.Before
+	}
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return string(data), nil
+}
+
+// auditQueries are the audit log fields of the root query
+func (h *UserHandler) auditQueries() graphql.Fields {
+	return graphql.Fields{
+		"auditEvents": guarded(hasPermission(service.PermAuditRead), &graphql.Field{
+			Type: auditEventConnectionType,
+			Args: graphql.FieldConfigArgument{
+				"filter": &graphql.ArgumentConfig{Type: auditFilterInput},
+				"first":  &graphql.ArgumentConfig{Type: graphql.Int},
+				"after":  &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				var filter model.AuditFilter
+				if f, ok := p.Args["filter"].(map[string]interface{}); ok {
+					filter.ActorID, _ = f["actorId"].(string)
+					filter.Action, _ = f["action"].(string)
+					filter.TargetID, _ = f["targetId"].(string)
+					filter.Since = timeArg(f["since"])
+					filter.Until = timeArg(f["until"])
+				}
+				var first *int
+				if n, ok := p.Args["first"].(int); ok {
+					first = &n
+				}
+				after, _ := p.Args["after"].(string)
+				return h.AuditService.GetAuditEvents(p.Context, filter, first, after)
+			},
+		}),
+	}
+}
+
+// ServeAuditExport streams the audit log as JSON lines. It takes the filter of the
+// auditEvents query as URL parameters, since and until in RFC 3339.
+func (h *UserHandler) ServeAuditExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apperror.CodeBadRequest, "only GET is supported")
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+	if !principal.HasPermission(service.PermAuditRead) {
+		writeError(w, http.StatusForbidden, apperror.CodeForbidden, errForbidden.Message)
+		return
+	}
+
+	params := r.URL.Query()
+	filter := model.AuditFilter{
+		ActorID:  params.Get("actorId"),
+		Action:   params.Get("action"),
+		TargetID: params.Get("targetId"),
+	}
+	for name, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apperror.CodeBadRequest, name+" must be an RFC 3339 time")
+			return
+		}
+		*dst = &t
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-events.jsonl"`)
+	// the status is out once the first line is written, a failure can only cut the export short
+	if err := h.AuditService.ExportAuditEvents(r.Context(), filter, w); err != nil {
+		log.Println(err)
+	}
+}
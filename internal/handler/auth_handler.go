@@ -1,11 +1,9 @@
 package handler
 
 import (
-	"encoding/json"
-	"fmt"
+	"go-graphql-user-svc/internal/apperror"
 	"go-graphql-user-svc/internal/model"
 	"go-graphql-user-svc/internal/service"
-	"log"
 	"net/http"
 
 	"github.com/graphql-go/graphql"
@@ -13,28 +11,46 @@ import (
 
 type AuthHandler struct {
 	Service service.IUserService
+	schema  graphql.Schema
 }
 
-func NewAuthHandler(svc service.IUserService) *AuthHandler {
-	return &AuthHandler{
+// NewAuthHandler creates a new handler for the public auth routes and builds its schema
+func NewAuthHandler(svc service.IUserService) (*AuthHandler, error) {
+	h := &AuthHandler{
 		Service: svc,
 	}
+	schema, err := h.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	h.schema = schema
+	return h, nil
 }
 
+// loginType carries either the tokens or, when a second factor is needed, the MFA challenge
 var loginType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Login",
 	Fields: graphql.Fields{
-		"token": &graphql.Field{Type: graphql.String},
+		"token":                 &graphql.Field{Type: graphql.String},
+		"refreshToken":          &graphql.Field{Type: graphql.String},
+		"expiresIn":             &graphql.Field{Type: graphql.Int},
+		"mfaRequired":           &graphql.Field{Type: graphql.Boolean},
+		"mfaEnrollmentRequired": &graphql.Field{Type: graphql.Boolean},
+		"mfaChallenge":          &graphql.Field{Type: graphql.String},
+		"recoveryCodes":         &graphql.Field{Type: graphql.NewList(graphql.String)},
 	},
 })
 
-func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	var params map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+var mfaEnrollmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MfaEnrollment",
+	Fields: graphql.Fields{
+		"secret":     &graphql.Field{Type: graphql.String},
+		"otpauthUri": &graphql.Field{Type: graphql.String},
+	},
+})
 
+// buildSchema creates the schema served on the public auth endpoint
+func (h *AuthHandler) buildSchema() (graphql.Schema, error) {
 	fields := graphql.Fields{
 		"login": &graphql.Field{
 			Type: loginType,
@@ -50,14 +66,7 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 					Password: password,
 				}
 
-				token, err := h.Service.Login(p.Context, user)
-				if err != nil {
-					return "", err
-				}
-
-				resp := make(map[string]interface{})
-				resp["token"] = token
-				return resp, nil
+				return h.Service.Login(p.Context, user, clientIPFromContext(p.Context))
 			},
 		},
 	}
@@ -66,17 +75,109 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		"register": &graphql.Field{
 			Type: userType,
 			Args: graphql.FieldConfigArgument{
-				"name":  &graphql.ArgumentConfig{Type: graphql.String},
-				"email": &graphql.ArgumentConfig{Type: graphql.String},
-				"role":  &graphql.ArgumentConfig{Type: graphql.String},
+				"name":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"email":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"password":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"inviteToken": &graphql.ArgumentConfig{Type: graphql.String},
 			},
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 				name := p.Args["name"].(string)
 				email := p.Args["email"].(string)
-				role := p.Args["role"].(string)
+				password := p.Args["password"].(string)
+				inviteToken, _ := p.Args["inviteToken"].(string)
 
-				user := model.User{Name: name, Email: email, Role: role}
-				return h.Service.CreateUser(p.Context, user)
+				user := model.User{Name: name, Email: email, Password: password}
+				return h.Service.Register(p.Context, user, inviteToken)
+			},
+		},
+		"refreshToken": &graphql.Field{
+			Type: loginType,
+			Args: graphql.FieldConfigArgument{
+				"refreshToken": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				refreshToken := p.Args["refreshToken"].(string)
+				return h.Service.RefreshToken(p.Context, refreshToken)
+			},
+		},
+		"verifyMfa": &graphql.Field{
+			Type: loginType,
+			Args: graphql.FieldConfigArgument{
+				"challenge": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"code":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				challenge := p.Args["challenge"].(string)
+				code := p.Args["code"].(string)
+				return h.Service.VerifyMFA(p.Context, challenge, code)
+			},
+		},
+		"enrollMfa": &graphql.Field{
+			Type: mfaEnrollmentType,
+			Args: graphql.FieldConfigArgument{
+				"challenge": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				challenge := p.Args["challenge"].(string)
+				return h.Service.EnrollMFAWithChallenge(p.Context, challenge)
+			},
+		},
+		"sendVerificationEmail": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				email := p.Args["email"].(string)
+				err := h.Service.SendVerificationEmail(p.Context, email)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+		"verifyEmail": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"token": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				token := p.Args["token"].(string)
+				err := h.Service.VerifyEmail(p.Context, token)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+		"requestPasswordReset": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				email := p.Args["email"].(string)
+				err := h.Service.RequestPasswordReset(p.Context, email)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+		"resetPassword": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"token":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"newPassword": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				token := p.Args["token"].(string)
+				newPassword := p.Args["newPassword"].(string)
+				err := h.Service.ResetPassword(p.Context, token, newPassword)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
 			},
 		},
 	}
@@ -85,21 +186,16 @@ func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		Query:    graphql.NewObject(rootQuery),
 		Mutation: graphql.NewObject(rootMutation),
 	}
-	schema, err := graphql.NewSchema(schemaConfig)
-	if err != nil {
-		log.Fatalf("failed to create new schema, error: %v", err)
-	}
-
-	result := graphql.Do(graphql.Params{
-		Schema:        schema,
-		RequestString: params["query"].(string),
-	})
+	return graphql.NewSchema(schemaConfig)
+}
 
-	if len(result.Errors) > 0 {
-		http.Error(w, fmt.Sprintf("Failed to execute GraphQL operation: %v", result.Errors), http.StatusInternalServerError)
+// Handle handles GraphQL requests for login and registration. Only POST is accepted, so
+// passwords and tokens never end up in URLs, access logs or browser history.
+func (h *AuthHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, apperror.CodeBadRequest, "only POST is supported")
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	serveGraphQL(w, r, h.schema)
 }
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/model"
+	servicemocks "go-graphql-user-svc/internal/service/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthEndpointOnlyAcceptsPOST(t *testing.T) {
+	const query = `{ login(email: "ada@example.com", password: "correct horse") { token } }`
+	svc := servicemocks.NewIUserService(t)
+	svc.On("Login", mock.Anything, model.User{Email: "ada@example.com", Password: "correct horse"}, "").Return(&model.AuthToken{Token: "token"}, nil).Once()
+	h, err := NewAuthHandler(svc)
+	require.NoError(t, err)
+
+	// the password would end up in the URL
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodGet, "/user-svc/auth?query="+url.QueryEscape(query), nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+
+	rec = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/user-svc/auth", strings.NewReader(query))
+	r.Header.Set("Content-Type", "application/graphql")
+	h.Handle(rec, r)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"token":"token"`)
+}
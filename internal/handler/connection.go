@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/model"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+var userEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: userType},
+	},
+})
+
+var userConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserConnection",
+	Fields: graphql.Fields{
+		"edges":      &graphql.Field{Type: graphql.NewList(userEdgeType)},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var userFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"role":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"organizationId": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"groupId":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"emailPrefix":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"nameContains":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"createdAfter":   &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"createdBefore":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"updatedAfter":   &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"updatedBefore":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+	},
+})
+
+var userOrderFieldEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserOrderField",
+	Values: graphql.EnumValueConfigMap{
+		"ID":         &graphql.EnumValueConfig{Value: model.UserOrderByID},
+		"CREATED_AT": &graphql.EnumValueConfig{Value: model.UserOrderByCreatedAt},
+	},
+})
+
+var orderDirectionEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "OrderDirection",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: "ASC"},
+		"DESC": &graphql.EnumValueConfig{Value: "DESC"},
+	},
+})
+
+var userOrderInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserOrder",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field":     &graphql.InputObjectFieldConfig{Type: userOrderFieldEnum, DefaultValue: model.UserOrderByCreatedAt},
+		"direction": &graphql.InputObjectFieldConfig{Type: orderDirectionEnum, DefaultValue: "ASC"},
+	},
+})
+
+// userConnectionArgs reads the arguments of a user connection field
+func userConnectionArgs(args map[string]interface{}) model.UserConnectionArgs {
+	var c model.UserConnectionArgs
+	if first, ok := args["first"].(int); ok {
+		c.First = &first
+	}
+	if last, ok := args["last"].(int); ok {
+		c.Last = &last
+	}
+	c.After, _ = args["after"].(string)
+	c.Before, _ = args["before"].(string)
+
+	if filter, ok := args["filter"].(map[string]interface{}); ok {
+		c.Filter.Role, _ = filter["role"].(string)
+		c.Filter.TenantID, _ = filter["organizationId"].(string)
+		c.Filter.GroupID, _ = filter["groupId"].(string)
+		c.Filter.EmailPrefix, _ = filter["emailPrefix"].(string)
+		c.Filter.NameContains, _ = filter["nameContains"].(string)
+		c.Filter.CreatedAfter = timeArg(filter["createdAfter"])
+		c.Filter.CreatedBefore = timeArg(filter["createdBefore"])
+		c.Filter.UpdatedAfter = timeArg(filter["updatedAfter"])
+		c.Filter.UpdatedBefore = timeArg(filter["updatedBefore"])
+	}
+	if order, ok := args["orderBy"].(map[string]interface{}); ok {
+		c.OrderBy, _ = order["field"].(model.UserOrderField)
+		c.Descending = order["direction"] == "DESC"
+	}
+	return c
+}
+
+func timeArg(v interface{}) *time.Time {
+	if t, ok := v.(time.Time); ok {
+		return &t
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/auth"
+
+	"github.com/graphql-go/graphql"
+)
+
+// fieldRule decides whether the principal may resolve a field, it is nil for anonymous callers
+type fieldRule func(p graphql.ResolveParams, principal *auth.Principal) bool
+
+// guarded makes the field's resolver run only when the rule allows the caller, every
+// denial is answered with the same FORBIDDEN error
+func guarded(rule fieldRule, field *graphql.Field) *graphql.Field {
+	resolve := field.Resolve
+	field.Resolve = func(p graphql.ResolveParams) (interface{}, error) {
+		principal, _ := auth.FromContext(p.Context)
+		if !rule(p, principal) {
+			return nil, errForbidden
+		}
+		return resolve(p)
+	}
+	return field
+}
+
+// authenticated allows any caller with a valid token
+func authenticated() fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		return principal != nil && principal.UserID != ""
+	}
+}
+
+// hasRole allows callers whose token carries one of the roles
+func hasRole(roles ...string) fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		if principal == nil {
+			return false
+		}
+		for _, r := range roles {
+			if principal.Role == r {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hasPermission allows callers whose role grants the permission
+func hasPermission(permission string) fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		return authenticated()(p, principal) && principal.HasPermission(permission)
+	}
+}
+
+// isSelf allows callers asking about themselves through the named id argument
+func isSelf(arg string) fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		id, _ := p.Args[arg].(string)
+		return principal != nil && id != "" && id == principal.UserID
+	}
+}
+
+// isSelfUser allows callers resolving a field of their own User
+func isSelfUser() fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		user := userFromSource(p.Source)
+		return principal != nil && user != nil && user.ID != "" && string(user.ID) == principal.UserID
+	}
+}
+
+// anyOf allows the caller when at least one of the rules does
+func anyOf(rules ...fieldRule) fieldRule {
+	return func(p graphql.ResolveParams, principal *auth.Principal) bool {
+		for _, rule := range rules {
+			if rule(p, principal) {
+				return true
+			}
+		}
+		return false
+	}
+}
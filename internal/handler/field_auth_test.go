@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldRules(t *testing.T) {
+	admin := &auth.Principal{UserID: "6740a6a9a5a4cf3a1c5d1e01", Role: "Admin", Permissions: []string{service.PermUserRead}}
+	user := &auth.Principal{UserID: "6740a6a9a5a4cf3a1c5d1e02", Role: "User"}
+	selfOrReader := anyOf(isSelf("id"), hasPermission(service.PermUserRead))
+
+	tests := []struct {
+		name      string
+		rule      fieldRule
+		principal *auth.Principal
+		args      map[string]interface{}
+		source    interface{}
+		want      bool
+	}{
+		{name: "authenticated", rule: authenticated(), principal: user, want: true},
+		{name: "anonymous", rule: authenticated()},
+		{name: "role held", rule: hasRole("Support", "Admin"), principal: admin, want: true},
+		{name: "role not held", rule: hasRole("Support", "Admin"), principal: user},
+		{name: "role of an anonymous caller", rule: hasRole("Support", "Admin")},
+		{name: "permission held", rule: hasPermission(service.PermUserRead), principal: admin, want: true},
+		{name: "permission not held", rule: hasPermission(service.PermUserRead), principal: user},
+		{name: "self", rule: selfOrReader, principal: user, args: map[string]interface{}{"id": "6740a6a9a5a4cf3a1c5d1e02"}, want: true},
+		{name: "someone else", rule: selfOrReader, principal: user, args: map[string]interface{}{"id": "6740a6a9a5a4cf3a1c5d1e01"}},
+		{name: "someone else as a reader", rule: selfOrReader, principal: admin, args: map[string]interface{}{"id": "6740a6a9a5a4cf3a1c5d1e02"}, want: true},
+		{name: "field of oneself", rule: isSelfUser(), principal: user, source: model.User{ID: "6740a6a9a5a4cf3a1c5d1e02"}, want: true},
+		{name: "field of someone else", rule: isSelfUser(), principal: user, source: &model.User{ID: "6740a6a9a5a4cf3a1c5d1e01"}},
+		{name: "field of an anonymous caller", rule: isSelfUser(), source: &model.User{ID: "6740a6a9a5a4cf3a1c5d1e02"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved := false
+			field := guarded(tt.rule, &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				resolved = true
+				return "ok", nil
+			}})
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.NewContext(ctx, tt.principal)
+			}
+
+			got, err := field.Resolve(graphql.ResolveParams{Context: ctx, Args: tt.args, Source: tt.source})
+			assert.Equal(t, tt.want, resolved)
+			if tt.want {
+				assert.Equal(t, "ok", got)
+				return
+			}
+			assert.Equal(t, errForbidden, err)
+		})
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/audit"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/mailer"
 	"go-graphql-user-svc/internal/repository"
 	"go-graphql-user-svc/internal/service"
 	"go-graphql-user-svc/util"
 	"log"
+	"net"
 	"net/http"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v4"
+	"time"
 )
 
 func corsMiddleware(next http.Handler) http.Handler {
@@ -32,46 +36,130 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func getJWTMiddleWare(cfg *config.Config) func(http.Handler) http.Handler {
-	var jwtKey = []byte(cfg.AuthTokenConfig.SecretKey)
+// localeMiddleware picks the preferred language of the caller for the messages we send them
+func localeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := r.Header.Get("Accept-Language")
+		if i := strings.IndexAny(lang, ",;"); i >= 0 {
+			lang = lang[:i]
+		}
+		if i := strings.Index(lang, "-"); i >= 0 {
+			lang = lang[:i]
+		}
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang != "" && lang != "*" {
+			r = r.WithContext(mailer.ContextWithLocale(r.Context(), lang))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type contextKey string
+
+const clientIPKey contextKey = "clientIP"
+
+// clientIPMiddleware remembers the address of the caller. The proxy headers are only
+// believed when the server is configured to run behind a reverse proxy.
+func clientIPMiddleware(trustProxyHeaders bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			if trustProxyHeaders {
+				if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+					ip = realIP
+				} else if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+					// the last entry is the one our proxy added, the others are up to the client
+					parts := strings.Split(fwd, ",")
+					ip = strings.TrimSpace(parts[len(parts)-1])
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey, ip)))
+		})
+	}
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// requestInfoMiddleware remembers what the audit log records about the request, it runs
+// after clientIPMiddleware. Requests without an X-Request-ID get one, it is echoed back either way.
+func requestInfoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if requestID == "" || len(requestID) > 128 {
+			id, err := util.GenerateRandomToken(12)
+			if err != nil {
+				log.Println(err)
+			}
+			requestID = id
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		info := audit.RequestInfo{
+			IP:        clientIPFromContext(r.Context()),
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		}
+		next.ServeHTTP(w, r.WithContext(audit.NewContext(r.Context(), info)))
+	})
+}
+
+func getJWTMiddleWare(keys *util.KeySet, redisRepo repository.RedisRepository, authz service.Authorizer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract the token from the Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Missing Authorization Header", http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, apperror.CodeUnauthenticated, "missing Authorization header")
 				return
 			}
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				// Validate the token signing method (use RS256, HS256, etc.)
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method")
-				}
-				return jwtKey, nil
-			})
-
-			if err != nil || !token.Valid {
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			// The key set validates the signing method against the key picked by kid
+			claims, err := util.VerifyToken(tokenString, keys)
+			if err != nil || claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+				writeError(w, http.StatusUnauthorized, apperror.CodeUnauthenticated, "invalid or expired token")
 				return
 			}
 
-			// Token is valid, add it to the context
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok || !token.Valid {
-				http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			// Reject tokens that were logged out or revoked for the whole user
+			revoked, err := redisRepo.IsAccessTokenRevoked(r.Context(), claims.RegisteredClaims.ID, claims.ID, claims.IssuedAt.Time)
+			if err != nil {
+				log.Println(err)
+				writeError(w, http.StatusInternalServerError, apperror.CodeInternal, "internal error")
+				return
+			}
+			if revoked {
+				writeError(w, http.StatusUnauthorized, apperror.CodeUnauthenticated, "token has been revoked")
 				return
 			}
 
-			// Optionally, you can add user data from claims into the context
-			ctx := r.Context()
-			ctx = context.WithValue(ctx, "claims", claims)
+			method := auth.MethodFromAMR(claims.AuthMethods)
+			principal := &auth.Principal{
+				UserID:      claims.ID,
+				Role:        claims.Role,
+				Groups:      claims.Groups,
+				Permissions: authz.Permissions(claims.Role, claims.Groups),
+				TenantID:    claims.TenantID,
+				TokenID:     claims.RegisteredClaims.ID,
+				AuthMethod:  method,
+				IssuedAt:    claims.IssuedAt.Time,
+				ExpiresAt:   claims.ExpiresAt.Time,
+			}
+
+			// Only callers who manage organizations see users beyond their own
+			ctx := auth.NewContext(r.Context(), principal)
+			if !principal.HasPermission(service.PermOrgManage) {
+				ctx = repository.WithTenant(ctx, principal.TenantID)
+			}
 
-			// Proceed with the next handler, passing the updated context
-			r = r.WithContext(ctx)
-			next.ServeHTTP(w, r)
+			// Proceed with the next handler, passing the caller along in the context
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -79,14 +167,85 @@ func getJWTMiddleWare(cfg *config.Config) func(http.Handler) http.Handler {
 func SetupServer() {
 	cfg := config.GetConfig()
 	db := util.GetMongoDB(cfg)
-	claimsValidator := service.NewClaimsValidator()
+	rc := util.GetRedisClient(cfg)
+	keySet, err := util.LoadKeySet(cfg.AuthTokenConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
 	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo, cfg)
-	userHandler := NewUserHandler(userService, claimsValidator)
-	authHandler := NewAuthHandler(userService)
-	jwtMiddleware := getJWTMiddleWare(cfg)
-	http.Handle("/user-svc", corsMiddleware(jwtMiddleware(http.HandlerFunc(userHandler.ServeGraphQL))))
-	http.Handle("/user-svc/auth", corsMiddleware(http.HandlerFunc(authHandler.Handle)))
+	if err := userRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Println(err)
+	}
+	if err := userRepo.BackfillEmailVerified(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	orgRepo := repository.NewOrganizationRepository(db)
+	if err := orgRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Println(err)
+	}
+	roleRepo := repository.NewRoleRepository(db)
+	if err := roleRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Println(err)
+	}
+	groupRepo := repository.NewGroupRepository(db)
+	if err := groupRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Println(err)
+	}
+	auditRepo := repository.NewAuditRepository(db)
+	if err := auditRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Println(err)
+	}
+	auditLogger := service.NewAuditLogger(auditRepo)
+	authorizer := service.NewRoleAuthorizer(roleRepo, groupRepo, cfg.RoleCacheTTL*time.Second)
+	roleService := service.NewRoleService(roleRepo, userRepo, authorizer, auditLogger, cfg)
+	if err := roleService.SeedRoles(context.Background(), cfg.Roles); err != nil {
+		log.Fatal(err)
+	}
+	redisRepo := repository.NewRedisRepository(rc, cfg)
+	mail, err := mailer.New(cfg.MailConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwordPolicy, err := service.NewPasswordPolicy(cfg.PasswordPolicyConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hasher, err := util.NewPasswordHasher(cfg.PasswordHashConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var secrets *util.SecretBox
+	if cfg.MFAConfig.EncryptionKey != "" {
+		secrets, err = util.NewSecretBox(cfg.MFAConfig.EncryptionKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if len(cfg.MFAConfig.RequiredRoles) > 0 {
+		log.Fatal("mfa.required-roles needs mfa.encryption-key")
+	}
+	userService := service.NewUserService(userRepo, roleRepo, orgRepo, groupRepo, redisRepo, keySet, hasher, secrets, mail, passwordPolicy, auditLogger, cfg)
+	deleted := cfg.DeletedUsersConfig
+	if deleted.PurgeInterval > 0 {
+		purger := service.NewUserPurger(userRepo, deleted.Retention*time.Second, deleted.PurgeInterval*time.Second)
+		go purger.Run(context.Background())
+	}
+	orgService := service.NewOrganizationService(orgRepo, userRepo, auditLogger)
+	groupService := service.NewGroupService(groupRepo, roleRepo, userRepo, authorizer, auditLogger)
+	auditService := service.NewAuditService(auditRepo)
+	userHandler, err := NewUserHandler(userService, roleService, orgService, groupService, auditService)
+	if err != nil {
+		log.Fatalf("failed to create user schema, error: %v", err)
+	}
+	authHandler, err := NewAuthHandler(userService)
+	if err != nil {
+		log.Fatalf("failed to create auth schema, error: %v", err)
+	}
+	jwtMiddleware := getJWTMiddleWare(keySet, redisRepo, authorizer)
+	clientIP := clientIPMiddleware(cfg.ServerConfig.TrustProxyHeaders)
+	http.Handle("/user-svc", corsMiddleware(localeMiddleware(clientIP(requestInfoMiddleware(jwtMiddleware(http.HandlerFunc(userHandler.ServeGraphQL)))))))
+	http.Handle("/user-svc/auth", corsMiddleware(localeMiddleware(clientIP(requestInfoMiddleware(http.HandlerFunc(authHandler.Handle))))))
+	http.Handle("/user-svc/audit/export", corsMiddleware(clientIP(requestInfoMiddleware(jwtMiddleware(http.HandlerFunc(userHandler.ServeAuditExport))))))
+	http.Handle("/.well-known/jwks.json", corsMiddleware(NewJWKSHandler(keySet)))
 
 	var serverMsg = fmt.Sprintf(
 		"GraphQL server running at http://localhost:%v/user-svc",
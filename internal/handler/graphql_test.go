@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/internal/repository/mocks"
+	servicemocks "go-graphql-user-svc/internal/service/mocks"
+	"go-graphql-user-svc/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTMiddlewareRevocation(t *testing.T) {
+	keys := util.NewHMACKeySet("test")
+	claims := util.Claims{ID: "6740a6a9a5a4cf3a1c5d1e01", Role: "User"}
+	claims.RegisteredClaims.ID = "jti"
+	claims.TenantID = "org1"
+	token, err := util.GenerateToken(claims, 900, keys)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		revoked  bool
+		wantCode int
+	}{
+		{name: "live token", wantCode: http.StatusOK},
+		{name: "revoked token", revoked: true, wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisRepo := mocks.NewRedisRepository(t)
+			redisRepo.On("IsAccessTokenRevoked", mock.Anything, "jti", "6740a6a9a5a4cf3a1c5d1e01", mock.AnythingOfType("time.Time")).Return(tt.revoked, nil)
+			authz := servicemocks.NewAuthorizer(t)
+			authz.On("Permissions", "User", []string(nil)).Return([]string{"user:read"}).Maybe()
+			var principal *auth.Principal
+			var tenantID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, _ = auth.FromContext(r.Context())
+				tenantID, _ = repository.TenantFromContext(r.Context())
+			})
+			r := httptest.NewRequest(http.MethodPost, "/user-svc", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			getJWTMiddleWare(keys, redisRepo, authz)(next).ServeHTTP(w, r)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.revoked {
+				assert.Nil(t, principal)
+				return
+			}
+			assert.Equal(t, "6740a6a9a5a4cf3a1c5d1e01", principal.UserID)
+			assert.Equal(t, []string{"user:read"}, principal.Permissions)
+			assert.Equal(t, "org1", tenantID, "the caller only sees the users of its organization")
+		})
+	}
+}
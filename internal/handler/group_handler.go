@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+
+	"github.com/graphql-go/graphql"
+)
+
+var groupType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Group",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.String},
+		"name":        &graphql.Field{Type: graphql.String},
+		"roles":       &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"permissions": &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"organizationId": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if group := groupFromSource(p.Source); group != nil && group.TenantID != "" {
+					return group.TenantID, nil
+				}
+				return nil, nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if group := groupFromSource(p.Source); group != nil {
+					return group.CreatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if group := groupFromSource(p.Source); group != nil {
+					return group.UpdatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+// addGroupFields adds the fields through which Group and User refer to each other, they can
+// only be added once both types exist
+func (h *UserHandler) addGroupFields() {
+	groupType.AddFieldConfig("members", guarded(hasPermission(service.PermUserRead), &graphql.Field{
+		Type: userConnectionType,
+		Args: graphql.FieldConfigArgument{
+			"first":   &graphql.ArgumentConfig{Type: graphql.Int},
+			"after":   &graphql.ArgumentConfig{Type: graphql.String},
+			"last":    &graphql.ArgumentConfig{Type: graphql.Int},
+			"before":  &graphql.ArgumentConfig{Type: graphql.String},
+			"filter":  &graphql.ArgumentConfig{Type: userFilterInput},
+			"orderBy": &graphql.ArgumentConfig{Type: userOrderInput},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			group := groupFromSource(p.Source)
+			if group == nil {
+				return nil, nil
+			}
+			args := userConnectionArgs(p.Args)
+			args.Filter.GroupID = string(group.ID)
+			return h.Service.GetUsersConnection(p.Context, args)
+		},
+	}))
+	userType.AddFieldConfig("groups", guarded(anyOf(isSelfUser(), hasPermission(service.PermGroupManage)), &graphql.Field{
+		Type: graphql.NewList(groupType),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user := userFromSource(p.Source)
+			if user == nil || h.GroupService == nil {
+				return nil, nil
+			}
+			return h.GroupService.GetGroupsByIDs(p.Context, user.GroupIDs)
+		},
+	}))
+}
+
+func groupFromSource(source interface{}) *model.Group {
+	switch group := source.(type) {
+	case *model.Group:
+		return group
+	case model.Group:
+		return &group
+	}
+	return nil
+}
+
+// groupQueries are the group fields of the root query
+func (h *UserHandler) groupQueries() graphql.Fields {
+	return graphql.Fields{
+		"groups": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: graphql.NewList(groupType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.GroupService.GetGroups(p.Context)
+			},
+		}),
+		"group": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: groupType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				return h.GroupService.GetGroup(p.Context, id)
+			},
+		}),
+	}
+}
+
+// groupMutations are the group fields of the root mutation
+func (h *UserHandler) groupMutations() graphql.Fields {
+	return graphql.Fields{
+		"createGroup": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: groupType,
+			Args: graphql.FieldConfigArgument{
+				"name":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"roles":       &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				"permissions": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				group := model.Group{
+					Name:        p.Args["name"].(string),
+					Roles:       stringList(p.Args["roles"]),
+					Permissions: stringList(p.Args["permissions"]),
+				}
+				return h.GroupService.CreateGroup(p.Context, group)
+			},
+		}),
+		"updateGroup": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: groupType,
+			Args: graphql.FieldConfigArgument{
+				"id":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"roles":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				"permissions": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				return h.GroupService.UpdateGroup(p.Context, id, stringList(p.Args["roles"]), stringList(p.Args["permissions"]))
+			},
+		}),
+		"deleteGroup": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				err := h.GroupService.DeleteGroup(p.Context, id)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"addGroupMember": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: userType,
+			Args: graphql.FieldConfigArgument{
+				"groupId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"userId":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				groupID := p.Args["groupId"].(string)
+				userID := p.Args["userId"].(string)
+				return h.Service.AddUserToGroup(p.Context, userID, groupID)
+			},
+		}),
+		"removeGroupMember": guarded(hasPermission(service.PermGroupManage), &graphql.Field{
+			Type: userType,
+			Args: graphql.FieldConfigArgument{
+				"groupId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"userId":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				groupID := p.Args["groupId"].(string)
+				userID := p.Args["userId"].(string)
+				return h.Service.RemoveUserFromGroup(p.Context, userID, groupID)
+			},
+		}),
+	}
+}
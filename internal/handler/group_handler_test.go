@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+	servicemocks "go-graphql-user-svc/internal/service/mocks"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupFieldsAreGuarded(t *testing.T) {
+	const (
+		selfID  = "6740a6a9a5a4cf3a1c5d1e01"
+		otherID = "6740a6a9a5a4cf3a1c5d1e02"
+	)
+	tests := []struct {
+		name        string
+		query       string
+		permissions []string
+		wantDenied  bool
+	}{
+		{name: "members without user:read", query: `{ group(id: "g1") { members { totalCount } } }`, permissions: []string{service.PermGroupManage}, wantDenied: true},
+		{name: "members", query: `{ group(id: "g1") { members { totalCount } } }`, permissions: []string{service.PermGroupManage, service.PermUserRead}},
+		{name: "groups of someone else", query: `{ getUser(id: "` + otherID + `") { groups { id } } }`, permissions: []string{service.PermUserRead}, wantDenied: true},
+		{name: "groups of someone else as group manager", query: `{ getUser(id: "` + otherID + `") { groups { id } } }`, permissions: []string{service.PermUserRead, service.PermGroupManage}},
+		{name: "own groups", query: `{ me { groups { id } } }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := servicemocks.NewIUserService(t)
+			groups := servicemocks.NewIGroupService(t)
+			users.On("GetUserByID", mock.Anything, mock.Anything).Return(func(_ context.Context, id string) *model.User {
+				return &model.User{ID: model.MOID(id), GroupIDs: []string{"g1"}}
+			}, nil).Maybe()
+			users.On("GetUsersConnection", mock.Anything, mock.Anything).Return(&model.UserConnection{}, nil).Maybe()
+			groups.On("GetGroup", mock.Anything, "g1").Return(&model.Group{ID: "g1"}, nil).Maybe()
+			groups.On("GetGroupsByIDs", mock.Anything, []string{"g1"}).Return([]model.Group{{ID: "g1"}}, nil).Maybe()
+			h, err := NewUserHandler(users, nil, nil, groups, nil)
+			require.NoError(t, err)
+			ctx := auth.NewContext(context.Background(), &auth.Principal{UserID: selfID, Permissions: tt.permissions})
+
+			res := graphql.Do(graphql.Params{Schema: h.schema, RequestString: tt.query, Context: ctx})
+			if !tt.wantDenied {
+				assert.Empty(t, res.Errors)
+				return
+			}
+			require.Len(t, res.Errors, 1)
+			assert.Equal(t, errForbidden.Error(), res.Errors[0].Message)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"go-graphql-user-svc/util"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	Keys *util.KeySet
+}
+
+// NewJWKSHandler creates a handler publishing the public keys tokens are signed with
+func NewJWKSHandler(keys *util.KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		Keys: keys,
+	}
+}
+
+// ServeHTTP writes the key set as a JWKS document
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.Keys.JWKS())
+}
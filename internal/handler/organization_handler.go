@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+
+	"github.com/graphql-go/graphql"
+)
+
+var organizationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Organization",
+	Fields: graphql.Fields{
+		"id":   &graphql.Field{Type: graphql.String},
+		"name": &graphql.Field{Type: graphql.String},
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if org := organizationFromSource(p.Source); org != nil {
+					return org.CreatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if org := organizationFromSource(p.Source); org != nil {
+					return org.UpdatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+func organizationFromSource(source interface{}) *model.Organization {
+	switch org := source.(type) {
+	case *model.Organization:
+		return org
+	case model.Organization:
+		return &org
+	}
+	return nil
+}
+
+// organizationQueries are the organization fields of the root query
+func (h *UserHandler) organizationQueries() graphql.Fields {
+	return graphql.Fields{
+		"organizations": guarded(hasPermission(service.PermOrgManage), &graphql.Field{
+			Type: graphql.NewList(organizationType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.OrgService.GetOrganizations(p.Context)
+			},
+		}),
+		"organization": guarded(hasPermission(service.PermOrgManage), &graphql.Field{
+			Type: organizationType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				return h.OrgService.GetOrganization(p.Context, id)
+			},
+		}),
+	}
+}
+
+// organizationMutations are the organization fields of the root mutation
+func (h *UserHandler) organizationMutations() graphql.Fields {
+	return graphql.Fields{
+		"createOrganization": guarded(hasPermission(service.PermOrgManage), &graphql.Field{
+			Type: organizationType,
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				name := p.Args["name"].(string)
+				return h.OrgService.CreateOrganization(p.Context, name)
+			},
+		}),
+		"renameOrganization": guarded(hasPermission(service.PermOrgManage), &graphql.Field{
+			Type: organizationType,
+			Args: graphql.FieldConfigArgument{
+				"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				name := p.Args["name"].(string)
+				return h.OrgService.RenameOrganization(p.Context, id, name)
+			},
+		}),
+		"deleteOrganization": guarded(hasPermission(service.PermOrgManage), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				err := h.OrgService.DeleteOrganization(p.Context, id)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+	}
+}
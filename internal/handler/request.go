@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const maxRequestBodySize = 1 << 20
+
+// graphQLRequest is a GraphQL-over-HTTP request, see https://graphql.github.io/graphql-over-http/
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// requestError is a request that can't be executed, Status is the HTTP status to answer with
+type requestError struct {
+	Status  int
+	Message string
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+func badRequest(msg string) *requestError {
+	return &requestError{Status: http.StatusBadRequest, Message: msg}
+}
+
+// parseGraphQLRequest reads a GET request with query string parameters, a POST request with
+// a JSON body or a POST request with an application/graphql body
+func parseGraphQLRequest(r *http.Request) (*graphQLRequest, error) {
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		if err := readURLParams(r.URL.Query(), &req); err != nil {
+			return nil, err
+		}
+	case http.MethodPost:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, &requestError{Status: http.StatusUnsupportedMediaType, Message: "missing or invalid Content-Type"}
+		}
+		body := http.MaxBytesReader(nil, r.Body, maxRequestBodySize)
+		switch mediaType {
+		case "application/json":
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				return nil, badRequest("request body is not a valid GraphQL request: " + err.Error())
+			}
+		case "application/graphql":
+			query, err := io.ReadAll(body)
+			if err != nil {
+				return nil, badRequest(err.Error())
+			}
+			if err := readURLParams(r.URL.Query(), &req); err != nil {
+				return nil, err
+			}
+			req.Query = string(query)
+		default:
+			return nil, &requestError{Status: http.StatusUnsupportedMediaType, Message: "unsupported Content-Type " + mediaType}
+		}
+	default:
+		return nil, &requestError{Status: http.StatusMethodNotAllowed, Message: "only GET and POST are supported"}
+	}
+
+	if req.Query == "" {
+		return nil, badRequest("query is required")
+	}
+	if r.Method == http.MethodGet && isMutation(req) {
+		return nil, &requestError{Status: http.StatusMethodNotAllowed, Message: "mutations must be sent with POST"}
+	}
+	return &req, nil
+}
+
+func readURLParams(values url.Values, req *graphQLRequest) error {
+	req.Query = values.Get("query")
+	req.OperationName = values.Get("operationName")
+	if v := values.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return badRequest("variables must be a JSON object")
+		}
+	}
+	if v := values.Get("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return badRequest("extensions must be a JSON object")
+		}
+	}
+	return nil
+}
+
+// isMutation reports whether the operation the request selects is a mutation.
+// Syntax errors are left for graphql.Do to report.
+func isMutation(req graphQLRequest) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName != "" && (op.Name == nil || op.Name.Value != req.OperationName) {
+			continue
+		}
+		if op.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
+
+// serveGraphQL executes the request against the schema and writes the result
+func serveGraphQL(w http.ResponseWriter, r *http.Request, schema graphql.Schema) {
+	req, err := parseGraphQLRequest(r)
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			reqErr = badRequest(err.Error())
+		}
+		if reqErr.Status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "GET, POST")
+		}
+		writeError(w, reqErr.Status, apperror.CodeBadRequest, reqErr.Message)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+
+	formatErrors(result.Errors)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// formatErrors makes sure every error carries an extensions.code. Resolver errors that
+// aren't an *apperror.Error are unexpected and get masked as internal errors.
+func formatErrors(errs []gqlerrors.FormattedError) {
+	for i, fe := range errs {
+		var appErr *apperror.Error
+		if errors.As(resolverError(fe), &appErr) {
+			if appErr.Code == apperror.CodeInternal && appErr.Err != nil {
+				log.Println(appErr.Err)
+			}
+			continue
+		}
+		if len(fe.Path) == 0 {
+			// the document failed to parse or validate, nothing was executed
+			errs[i].Extensions = map[string]interface{}{"code": apperror.CodeBadRequest}
+			continue
+		}
+		log.Println(fe.Message)
+		errs[i].Message = "internal error"
+		errs[i].Extensions = map[string]interface{}{"code": apperror.CodeInternal}
+	}
+}
+
+// resolverError digs the error a resolver returned out of the *gqlerrors.Error graphql-go
+// wraps it in, that wrapper doesn't implement Unwrap
+func resolverError(fe gqlerrors.FormattedError) error {
+	err := fe.OriginalError()
+	if gqlErr, ok := err.(*gqlerrors.Error); ok && gqlErr.OriginalError != nil {
+		return gqlErr.OriginalError
+	}
+	return err
+}
+
+// writeError answers a request that never reached the resolvers
+func writeError(w http.ResponseWriter, status int, code apperror.Code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    message,
+			"extensions": map[string]interface{}{"code": code},
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
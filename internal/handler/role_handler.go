@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+
+	"github.com/graphql-go/graphql"
+)
+
+var roleType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Role",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.String},
+		"name":        &graphql.Field{Type: graphql.String},
+		"permissions": &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if role := roleFromSource(p.Source); role != nil {
+					return role.CreatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if role := roleFromSource(p.Source); role != nil {
+					return role.UpdatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+func roleFromSource(source interface{}) *model.Role {
+	switch role := source.(type) {
+	case *model.Role:
+		return role
+	case model.Role:
+		return &role
+	}
+	return nil
+}
+
+// roleQueries are the role fields of the root query
+func (h *UserHandler) roleQueries() graphql.Fields {
+	return graphql.Fields{
+		"roles": guarded(hasPermission(service.PermRoleManage), &graphql.Field{
+			Type: graphql.NewList(roleType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.RoleService.GetRoles(p.Context)
+			},
+		}),
+		"permissions": guarded(hasPermission(service.PermRoleManage), &graphql.Field{
+			Type: graphql.NewList(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return service.Permissions, nil
+			},
+		}),
+	}
+}
+
+// roleMutations are the role fields of the root mutation
+func (h *UserHandler) roleMutations() graphql.Fields {
+	return graphql.Fields{
+		"createRole": guarded(hasPermission(service.PermRoleManage), &graphql.Field{
+			Type: roleType,
+			Args: graphql.FieldConfigArgument{
+				"name":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"permissions": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				role := model.Role{
+					Name:        p.Args["name"].(string),
+					Permissions: stringList(p.Args["permissions"]),
+				}
+				return h.RoleService.CreateRole(p.Context, role)
+			},
+		}),
+		"updateRole": guarded(hasPermission(service.PermRoleManage), &graphql.Field{
+			Type: roleType,
+			Args: graphql.FieldConfigArgument{
+				"name":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"permissions": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				name := p.Args["name"].(string)
+				return h.RoleService.UpdateRole(p.Context, name, stringList(p.Args["permissions"]))
+			},
+		}),
+		"deleteRole": guarded(hasPermission(service.PermRoleManage), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				name := p.Args["name"].(string)
+				err := h.RoleService.DeleteRole(p.Context, name)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+	}
+}
+
+// stringList converts a list argument to a string slice
+func stringList(arg interface{}) []string {
+	items, _ := arg.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
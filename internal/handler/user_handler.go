@@ -1,29 +1,61 @@
 package handler
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
 	"go-graphql-user-svc/internal/model"
 	"go-graphql-user-svc/internal/service"
-	"log"
 	"net/http"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/graphql-go/graphql"
 )
 
+var errForbidden = apperror.Forbidden("you cannot access this resource")
+
 type UserHandler struct {
-	Service service.IUserService
-	cv      service.IClaimsValidator
+	Service      service.IUserService
+	RoleService  service.IRoleService
+	OrgService   service.IOrganizationService
+	GroupService service.IGroupService
+	AuditService service.IAuditService
+	schema       graphql.Schema
+}
+
+// NewUserHandler creates a new handler for user-related routes and builds its schema
+func NewUserHandler(service service.IUserService, roleService service.IRoleService, orgService service.IOrganizationService, groupService service.IGroupService, auditService service.IAuditService) (*UserHandler, error) {
+	h := &UserHandler{
+		Service:      service,
+		RoleService:  roleService,
+		OrgService:   orgService,
+		GroupService: groupService,
+		AuditService: auditService,
+	}
+	schema, err := h.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	h.schema = schema
+	return h, nil
 }
 
-// NewUserHandler creates a new handler for user-related routes
-func NewUserHandler(service service.IUserService, cv service.IClaimsValidator) *UserHandler {
-	return &UserHandler{
-		Service: service,
-		cv:      cv,
+// callerID returns the id of the principal behind the request, empty for anonymous requests
+func callerID(ctx context.Context) string {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return ""
 	}
+	return principal.UserID
+}
+
+// expectedVersionArg returns the expectedVersion argument, nil when it wasn't given
+func expectedVersionArg(args map[string]interface{}) *int64 {
+	v, ok := args["expectedVersion"].(int)
+	if !ok {
+		return nil
+	}
+	version := int64(v)
+	return &version
 }
 
 // GraphQL Object for User
@@ -34,129 +66,357 @@ var userType = graphql.NewObject(graphql.ObjectConfig{
 		"name":  &graphql.Field{Type: graphql.String},
 		"email": &graphql.Field{Type: graphql.String},
 		"role":  &graphql.Field{Type: graphql.String},
+		"organizationId": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil && user.TenantID != "" {
+					return user.TenantID, nil
+				}
+				return nil, nil
+			},
+		},
+		"status": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil {
+					if user.Status == "" {
+						return model.UserStatusActive, nil
+					}
+					return user.Status, nil
+				}
+				return nil, nil
+			},
+		},
+		"emailVerified": &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil {
+					return user.EmailVerified, nil
+				}
+				return nil, nil
+			},
+		},
+		"mfaEnabled": &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil {
+					return user.MFA.Enabled, nil
+				}
+				return nil, nil
+			},
+		},
+		"version": &graphql.Field{Type: graphql.Int},
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil {
+					return user.CreatedAt, nil
+				}
+				return nil, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if user := userFromSource(p.Source); user != nil {
+					return user.UpdatedAt, nil
+				}
+				return nil, nil
+			},
+		},
 	},
 })
 
-// ServeGraphQL handles GraphQL requests
-func (h *UserHandler) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
-
-	rCtx := r.Context()
-
-	var params map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// userFromSource returns the user a User field is resolved on, lists hold values and single lookups pointers
+func userFromSource(source interface{}) *model.User {
+	switch user := source.(type) {
+	case *model.User:
+		return user
+	case model.User:
+		return &user
 	}
+	return nil
+}
 
+// buildSchema creates the schema served on the authenticated endpoint
+func (h *UserHandler) buildSchema() (graphql.Schema, error) {
+	h.addGroupFields()
 	fields := graphql.Fields{
-		"getUser": &graphql.Field{
+		"getUser": guarded(anyOf(isSelf("id"), hasPermission(service.PermUserRead)), &graphql.Field{
 			Type: userType,
 			Args: graphql.FieldConfigArgument{
 				"id": &graphql.ArgumentConfig{Type: graphql.String},
 			},
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				id := p.Args["id"].(string)
+				id, _ := p.Args["id"].(string)
 				return h.Service.GetUserByID(p.Context, id)
 			},
-		},
-		"users": &graphql.Field{
+		}),
+		"me": guarded(authenticated(), &graphql.Field{
+			Type: userType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := callerID(p.Context)
+				return h.Service.GetUserByID(p.Context, userID)
+			},
+		}),
+		"usersConnection": guarded(hasPermission(service.PermUserRead), &graphql.Field{
+			Type: userConnectionType,
+			Args: graphql.FieldConfigArgument{
+				"first":   &graphql.ArgumentConfig{Type: graphql.Int},
+				"after":   &graphql.ArgumentConfig{Type: graphql.String},
+				"last":    &graphql.ArgumentConfig{Type: graphql.Int},
+				"before":  &graphql.ArgumentConfig{Type: graphql.String},
+				"filter":  &graphql.ArgumentConfig{Type: userFilterInput},
+				"orderBy": &graphql.ArgumentConfig{Type: userOrderInput},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.Service.GetUsersConnection(p.Context, userConnectionArgs(p.Args))
+			},
+		}),
+		"users": guarded(hasPermission(service.PermUserRead), &graphql.Field{
 			Type: graphql.NewList(userType),
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				p.Context = rCtx
-				if !h.cv.IsAdmin(rCtx.Value("claims").(jwt.MapClaims)) {
-					return nil, errors.New("you cannot access this resource")
-				}
 				return h.Service.GetAllUser(p.Context), nil
 			},
-		},
+		}),
+	}
+	for name, field := range h.roleQueries() {
+		fields[name] = field
+	}
+	for name, field := range h.organizationQueries() {
+		fields[name] = field
+	}
+	for name, field := range h.groupQueries() {
+		fields[name] = field
+	}
+	for name, field := range h.auditQueries() {
+		fields[name] = field
 	}
 	rootQuery := graphql.ObjectConfig{Name: "RootQuery", Fields: fields}
 	mutationFields := graphql.Fields{
-		"createUser": &graphql.Field{
+		"createUser": guarded(hasPermission(service.PermUserWrite), &graphql.Field{
 			Type: userType,
 			Args: graphql.FieldConfigArgument{
-				"name":     &graphql.ArgumentConfig{Type: graphql.String},
-				"email":    &graphql.ArgumentConfig{Type: graphql.String},
-				"role":     &graphql.ArgumentConfig{Type: graphql.String},
-				"password": &graphql.ArgumentConfig{Type: graphql.String},
+				"name":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"role":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				// defaults to the organization of the caller
+				"organizationId": &graphql.ArgumentConfig{Type: graphql.String},
 			},
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				p.Context = rCtx
-				if !h.cv.IsAdmin(rCtx.Value("claims").(jwt.MapClaims)) {
-					return nil, errors.New("you cannot access this resource")
-				}
 				name := p.Args["name"].(string)
 				email := p.Args["email"].(string)
 				role := p.Args["role"].(string)
 				password := p.Args["password"].(string)
+				tenantID, _ := p.Args["organizationId"].(string)
 
-				user := model.User{Name: name, Email: email, Role: role, Password: password}
+				user := model.User{Name: name, Email: email, Role: role, Password: password, TenantID: tenantID}
 				return h.Service.CreateUser(p.Context, user)
 			},
-		},
-		"updateUser": &graphql.Field{
+		}),
+		"updateUser": guarded(hasPermission(service.PermUserWrite), &graphql.Field{
 			Type: userType,
 			Args: graphql.FieldConfigArgument{
-				"id":       &graphql.ArgumentConfig{Type: graphql.String},
-				"name":     &graphql.ArgumentConfig{Type: graphql.String},
-				"email":    &graphql.ArgumentConfig{Type: graphql.String},
-				"role":     &graphql.ArgumentConfig{Type: graphql.String},
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"name":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"email": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"role":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				// left out or empty keeps the current password
 				"password": &graphql.ArgumentConfig{Type: graphql.String},
+				// the version the caller read, the update fails with CONFLICT when the user changed since
+				"expectedVersion": &graphql.ArgumentConfig{Type: graphql.Int},
 			},
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				p.Context = rCtx
-				if !h.cv.IsAdmin(rCtx.Value("claims").(jwt.MapClaims)) {
-					return nil, errors.New("you cannot access this resource")
-				}
 				id := p.Args["id"].(string)
 				name := p.Args["name"].(string)
 				email := p.Args["email"].(string)
 				role := p.Args["role"].(string)
-				password := p.Args["password"].(string)
+				password, _ := p.Args["password"].(string)
 
 				user := model.User{Name: name, Email: email, Role: role, Password: password}
-				return h.Service.UpdateUser(p.Context, id, user)
+				return h.Service.UpdateUser(p.Context, id, user, expectedVersionArg(p.Args))
 			},
-		},
-		"deleteUser": &graphql.Field{
+		}),
+		"updateMyProfile": guarded(authenticated(), &graphql.Field{
+			Type: userType,
+			Args: graphql.FieldConfigArgument{
+				"name":  &graphql.ArgumentConfig{Type: graphql.String},
+				"email": &graphql.ArgumentConfig{Type: graphql.String},
+				// like updateUser, a user who changed since the read fails with CONFLICT
+				"expectedVersion": &graphql.ArgumentConfig{Type: graphql.Int},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := callerID(p.Context)
+				var profile model.ProfileUpdate
+				if name, ok := p.Args["name"].(string); ok {
+					profile.Name = &name
+				}
+				if email, ok := p.Args["email"].(string); ok {
+					profile.Email = &email
+				}
+				return h.Service.UpdateProfile(p.Context, userID, profile, expectedVersionArg(p.Args))
+			},
+		}),
+		"changePassword": guarded(authenticated(), &graphql.Field{
 			Type: graphql.Boolean,
 			Args: graphql.FieldConfigArgument{
-				"id": &graphql.ArgumentConfig{Type: graphql.String},
+				"oldPassword": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"newPassword": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 			},
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				p.Context = rCtx
-				if !h.cv.IsAdmin(rCtx.Value("claims").(jwt.MapClaims)) {
-					return nil, errors.New("you cannot access this resource")
+				userID := callerID(p.Context)
+				oldPassword := p.Args["oldPassword"].(string)
+				newPassword := p.Args["newPassword"].(string)
+				err := h.Service.ChangePassword(p.Context, userID, oldPassword, newPassword)
+				if err != nil {
+					return false, err
 				}
+				return true, nil
+			},
+		}),
+		"enrollMfa": guarded(authenticated(), &graphql.Field{
+			Type: mfaEnrollmentType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := callerID(p.Context)
+				return h.Service.EnrollMFA(p.Context, userID)
+			},
+		}),
+		"confirmMfa": guarded(authenticated(), &graphql.Field{
+			Type: graphql.NewList(graphql.String),
+			Args: graphql.FieldConfigArgument{
+				"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := callerID(p.Context)
+				code := p.Args["code"].(string)
+				return h.Service.ConfirmMFA(p.Context, userID, code)
+			},
+		}),
+		"disableMfa": guarded(authenticated(), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := callerID(p.Context)
+				code := p.Args["code"].(string)
+				err := h.Service.DisableMFA(p.Context, userID, code)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"resetMfa": guarded(hasPermission(service.PermUserWrite), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 				id := p.Args["id"].(string)
+				err := h.Service.ResetMFA(p.Context, id)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"inviteUser": guarded(hasPermission(service.PermUserWrite), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				email := p.Args["email"].(string)
+				err := h.Service.InviteUser(p.Context, email, callerID(p.Context))
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"unlockUser": guarded(hasPermission(service.PermUserWrite), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				err := h.Service.UnlockUser(p.Context, id)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"deleteUser": guarded(hasPermission(service.PermUserDelete), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, _ := p.Args["id"].(string)
 				err := h.Service.DeleteUser(p.Context, id)
 				if err != nil {
 					return false, err
 				}
 				return true, nil
 			},
-		},
+		}),
+		"restoreUser": guarded(hasPermission(service.PermUserDelete), &graphql.Field{
+			Type: userType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id := p.Args["id"].(string)
+				return h.Service.RestoreUser(p.Context, id)
+			},
+		}),
+		"logout": guarded(authenticated(), &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"refreshToken": &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				refreshToken, _ := p.Args["refreshToken"].(string)
+				err := h.Service.Logout(p.Context, refreshToken)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+		"logoutAllSessions": guarded(authenticated(), &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				err := h.Service.LogoutAllSessions(p.Context, callerID(p.Context))
+				if err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		}),
+	}
+	for name, field := range h.roleMutations() {
+		mutationFields[name] = field
+	}
+	for name, field := range h.organizationMutations() {
+		mutationFields[name] = field
+	}
+	for name, field := range h.groupMutations() {
+		mutationFields[name] = field
 	}
 	rootMutation := graphql.ObjectConfig{Name: "RootMutation", Fields: mutationFields}
 	schemaConfig := graphql.SchemaConfig{
 		Query:    graphql.NewObject(rootQuery),
 		Mutation: graphql.NewObject(rootMutation),
 	}
-	schema, err := graphql.NewSchema(schemaConfig)
-	if err != nil {
-		log.Fatalf("failed to create new schema, error: %v", err)
-	}
-
-	result := graphql.Do(graphql.Params{
-		Schema:        schema,
-		RequestString: params["query"].(string),
-	})
-
-	if len(result.Errors) > 0 {
-		http.Error(w, fmt.Sprintf("Failed to execute GraphQL operation: %v", result.Errors), http.StatusInternalServerError)
-		return
-	}
+	return graphql.NewSchema(schemaConfig)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+// ServeGraphQL handles GraphQL requests
+func (h *UserHandler) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	serveGraphQL(w, r, h.schema)
 }
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const benchUsersQuery = `{"query": "{ users { id name email role } }"}`
+
+// benchUserService only implements what the benchmarked query needs
+type benchUserService struct {
+	service.IUserService
+	users []model.User
+}
+
+func (s *benchUserService) GetAllUser(ctx context.Context) *[]model.User {
+	return &s.users
+}
+
+func newBenchUserHandler(b *testing.B) *UserHandler {
+	svc := &benchUserService{users: []model.User{
+		{ID: "6740a6a9a5a4cf3a1c5d1e01", Name: "admin", Email: "admin@example.com", Role: "Admin"},
+		{ID: "6740a6a9a5a4cf3a1c5d1e02", Name: "user", Email: "user@example.com", Role: "User"},
+	}}
+	h, err := NewUserHandler(svc, nil, nil, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+func newBenchRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/user-svc", strings.NewReader(benchUsersQuery))
+	r.Header.Set("Content-Type", "application/json")
+	principal := &auth.Principal{
+		UserID:      "6740a6a9a5a4cf3a1c5d1e01",
+		Role:        "Admin",
+		Permissions: service.Permissions,
+	}
+	return r.WithContext(auth.NewContext(r.Context(), principal))
+}
+
+// BenchmarkServeGraphQL serves requests with the schema built once by NewUserHandler
+func BenchmarkServeGraphQL(b *testing.B) {
+	h := newBenchUserHandler(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeGraphQL(httptest.NewRecorder(), newBenchRequest())
+	}
+}
+
+// BenchmarkServeGraphQLRebuildSchema rebuilds the schema before every request,
+// which is what ServeGraphQL used to do
+func BenchmarkServeGraphQLRebuildSchema(b *testing.B) {
+	h := newBenchUserHandler(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		schema, err := h.buildSchema()
+		if err != nil {
+			b.Fatal(err)
+		}
+		h.schema = schema
+		h.ServeGraphQL(httptest.NewRecorder(), newBenchRequest())
+	}
+}
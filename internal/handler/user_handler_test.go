@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/service"
+	servicemocks "go-graphql-user-svc/internal/service/mocks"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMutationArguments(t *testing.T) {
+	const id = "6740a6a9a5a4cf3a1c5d1e02"
+	tests := []struct {
+		name       string
+		query      string
+		wantUpdate *model.User
+	}{
+		{
+			name:       "update without a password",
+			query:      `mutation { updateUser(id: "` + id + `", name: "Ada", email: "ada@example.com", role: "User") { id } }`,
+			wantUpdate: &model.User{Name: "Ada", Email: "ada@example.com", Role: "User"},
+		},
+		{name: "update without an email", query: `mutation { updateUser(id: "` + id + `", name: "Ada", role: "User") { id } }`},
+		{name: "update with a null role", query: `mutation { updateUser(id: "` + id + `", name: "Ada", email: "ada@example.com", role: null) { id } }`},
+		{name: "create without a password", query: `mutation { createUser(name: "Ada", email: "ada@example.com", role: "User") { id } }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := servicemocks.NewIUserService(t)
+			if tt.wantUpdate != nil {
+				svc.On("UpdateUser", mock.Anything, id, *tt.wantUpdate, (*int64)(nil)).Return(&model.User{ID: id}, nil)
+			}
+			h, err := NewUserHandler(svc, nil, nil, nil, nil)
+			require.NoError(t, err)
+			ctx := auth.NewContext(context.Background(), &auth.Principal{UserID: "6740a6a9a5a4cf3a1c5d1e01", Permissions: []string{service.PermUserWrite}})
+
+			// a missing argument is refused by validation instead of panicking in the resolver
+			res := graphql.Do(graphql.Params{Schema: h.schema, RequestString: tt.query, Context: ctx})
+			if tt.wantUpdate != nil {
+				assert.Empty(t, res.Errors)
+				return
+			}
+			require.NotEmpty(t, res.Errors)
+			assert.Empty(t, res.Errors[0].Path, "the document must fail validation before any resolver runs")
+		})
+	}
+}
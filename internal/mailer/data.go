@@ -0,0 +1,22 @@
+package mailer
+
+// PasswordResetData is rendered by the password_reset template
+type PasswordResetData struct {
+	Name         string
+	URL          string
+	ValidMinutes int
+}
+
+// EmailVerificationData is rendered by the email_verification template
+type EmailVerificationData struct {
+	Name       string
+	URL        string
+	ValidHours int
+}
+
+// InvitationData is rendered by the invitation template
+type InvitationData struct {
+	InvitedBy string
+	URL       string
+	ValidDays int
+}
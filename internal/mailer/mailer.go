@@ -0,0 +1,148 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/config"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateInvitation        = "invitation"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Mailer renders a templated message and schedules it for delivery
+type Mailer interface {
+	Send(ctx context.Context, to string, template string, data interface{}) error
+}
+
+// Message is a rendered email with a plain text and an optional html body
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport delivers a rendered message
+type Transport interface {
+	Deliver(ctx context.Context, msg Message) error
+}
+
+// AsyncMailer renders messages on the caller's goroutine so template errors surface
+// right away, and delivers them from a pool of workers that retry with backoff
+type AsyncMailer struct {
+	templates     *Templates
+	transport     Transport
+	from          string
+	defaultLocale string
+	maxAttempts   int
+	retryBackoff  time.Duration
+	queue         chan Message
+	wg            sync.WaitGroup
+}
+
+// New creates the mailer for the configured transport and starts its workers
+func New(cfg config.MailConfig) (*AsyncMailer, error) {
+	var transport Transport
+	switch cfg.Transport {
+	case "smtp":
+		transport = NewSMTPTransport(cfg.SMTP)
+	case "file":
+		transport = NewFileTransport(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("unsupported mail transport %q", cfg.Transport)
+	}
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncMailer(templates, transport, cfg), nil
+}
+
+func NewAsyncMailer(templates *Templates, transport Transport, cfg config.MailConfig) *AsyncMailer {
+	m := &AsyncMailer{
+		templates:     templates,
+		transport:     transport,
+		from:          cfg.From,
+		defaultLocale: cfg.DefaultLocale,
+		maxAttempts:   max(cfg.MaxAttempts, 1),
+		retryBackoff:  cfg.RetryBackoff * time.Second,
+		queue:         make(chan Message, max(cfg.QueueSize, 1)),
+	}
+	for i := 0; i < max(cfg.Workers, 1); i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+	return m
+}
+
+// Send renders the template in the locale found in ctx and queues the message
+func (m *AsyncMailer) Send(ctx context.Context, to string, template string, data interface{}) error {
+	locale := LocaleFromContext(ctx)
+	if locale == "" {
+		locale = m.defaultLocale
+	}
+	msg, err := m.templates.Render(template, locale, m.defaultLocale, data)
+	if err != nil {
+		return err
+	}
+	msg.From = m.from
+	msg.To = to
+
+	select {
+	case m.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be delivered
+func (m *AsyncMailer) Close() {
+	close(m.queue)
+	m.wg.Wait()
+}
+
+func (m *AsyncMailer) work() {
+	defer m.wg.Done()
+	for msg := range m.queue {
+		m.deliver(msg)
+	}
+}
+
+func (m *AsyncMailer) deliver(msg Message) {
+	backoff := m.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := m.transport.Deliver(context.Background(), msg)
+		if err == nil {
+			return
+		}
+		if attempt >= m.maxAttempts {
+			log.Printf("could not deliver %q to %s after %d attempts: %v", msg.Subject, msg.To, attempt, err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+type localeKey struct{}
+
+// ContextWithLocale sets the locale messages sent with ctx are rendered in
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
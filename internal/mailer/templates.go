@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates holds every message in every locale, each message is made of
+// <name>.subject.txt, <name>.txt and an optional <name>.html
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the templates embedded in the binary
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	err := fs.WalkDir(templateFS, "templates", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		src, err := templateFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimPrefix(path, "templates/")
+		if strings.HasSuffix(key, ".html") {
+			tmpl, err := htmltemplate.New(key).Parse(string(src))
+			if err != nil {
+				return err
+			}
+			t.html[key] = tmpl
+			return nil
+		}
+		tmpl, err := texttemplate.New(key).Parse(string(src))
+		if err != nil {
+			return err
+		}
+		t.text[key] = tmpl
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load mail templates: %v", err)
+	}
+	return t, nil
+}
+
+// Render renders the named message in locale, falling back to fallbackLocale when
+// the message isn't translated
+func (t *Templates) Render(name string, locale string, fallbackLocale string, data interface{}) (Message, error) {
+	if _, ok := t.text[locale+"/"+name+".subject.txt"]; !ok {
+		locale = fallbackLocale
+	}
+	prefix := locale + "/" + name
+
+	var msg Message
+	subject, ok := t.text[prefix+".subject.txt"]
+	if !ok {
+		return msg, fmt.Errorf("unknown mail template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := subject.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	if text, ok := t.text[prefix+".txt"]; ok {
+		buf.Reset()
+		if err := text.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		msg.Text = buf.String()
+	}
+	if html, ok := t.html[prefix+".html"]; ok {
+		buf.Reset()
+		if err := html.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMIMERecipient(t *testing.T) {
+	tests := []struct {
+		name string
+		to   string
+		want string
+	}{
+		{name: "address", to: "ada@example.com", want: "To: <ada@example.com>"},
+		{name: "line break", to: "ada@example.com\r\nBcc: eve@example.com", want: `To: <"ada@example.comBcc: eve"@example.com>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := buildMIME(Message{From: "noreply@example.com", To: tt.to, Subject: "Hello", Text: "Hello"})
+			require.NoError(t, err)
+			header, _, _ := strings.Cut(string(raw), "\r\n\r\n")
+			lines := strings.Split(header, "\r\n")
+			assert.Contains(t, lines, tt.want)
+			for _, line := range lines {
+				assert.False(t, strings.HasPrefix(line, "Bcc:"), "injected header %q", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	AuditUserCreate         = "user.create"
+	AuditUserRegister       = "user.register"
+	AuditUserInvite         = "user.invite"
+	AuditUserUpdate         = "user.update"
+	AuditUserUpdateProfile  = "user.update_profile"
+	AuditUserDelete         = "user.delete"
+	AuditUserRestore        = "user.restore"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserResetMFA       = "user.reset_mfa"
+	AuditUserAddGroup       = "user.add_group"
+	AuditUserRemoveGroup    = "user.remove_group"
+	AuditUserChangePassword = "user.change_password"
+	AuditUserResetPassword  = "user.reset_password"
+	AuditUserEnrollMFA      = "user.enroll_mfa"
+	AuditUserEnableMFA      = "user.enable_mfa"
+	AuditUserDisableMFA     = "user.disable_mfa"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditGroupCreate        = "group.create"
+	AuditGroupUpdate        = "group.update"
+	AuditGroupDelete        = "group.delete"
+	AuditOrgCreate          = "organization.create"
+	AuditOrgRename          = "organization.rename"
+	AuditOrgDelete          = "organization.delete"
+	AuditLogin              = "auth.login"
+	AuditLoginFailed        = "auth.login_failed"
+)
+
+// AuditEvent records who did what to whom, events are never changed once written
+type AuditEvent struct {
+	ID        MOID          `json:"id" bson:"_id,omitempty"`
+	TenantID  string        `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	ActorID   string        `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	Action    string        `json:"action" bson:"action"`
+	TargetID  string        `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	Changes   []AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	IP        string        `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	RequestID string        `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// AuditChange is a field that differs before and after an action, secrets only show up
+// as redacted
+type AuditChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// AuditFilter narrows down audit events, empty fields match every event
+type AuditFilter struct {
+	ActorID  string
+	Action   string
+	TargetID string
+	Since    *time.Time
+	Until    *time.Time
+}
+
+// AuditEventConnection is one page of audit events, newest first
+type AuditEventConnection struct {
+	Edges    []AuditEventEdge `json:"edges"`
+	PageInfo PageInfo         `json:"pageInfo"`
+}
+
+type AuditEventEdge struct {
+	Cursor string     `json:"cursor"`
+	Node   AuditEvent `json:"node"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Group collects users of an organization, its members get the permissions of its roles
+// and its own permissions on top of their direct role
+type Group struct {
+	ID          MOID      `json:"id" bson:"_id,omitempty"`
+	TenantID    string    `json:"tenant_id" bson:"tenant_id,omitempty"`
+	Name        string    `json:"name" bson:"name"`
+	Roles       []string  `json:"roles" bson:"roles"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+// MFA is the TOTP state of a user. The secrets and recovery codes are encrypted,
+// a recovery code is removed once it was used.
+type MFA struct {
+	Enabled       bool     `json:"enabled" bson:"enabled"`
+	Secret        string   `json:"-" bson:"secret,omitempty"`
+	PendingSecret string   `json:"-" bson:"pending_secret,omitempty"`
+	RecoveryCodes []string `json:"-" bson:"recovery_codes,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, a code is only accepted once
+	LastUsedStep int64 `json:"-" bson:"last_used_step"`
+}
+
+// MFAEnrollment is what an authenticator app needs to be set up
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+// MFAChallenge is the state between a login with the right password and the second factor.
+// An enrollment challenge belongs to a user who has to set up MFA before signing in.
+type MFAChallenge struct {
+	UserID     string
+	Enrollment bool
+	Failures   int64
+}
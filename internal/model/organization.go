@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Organization is a tenant, its users and admins only ever see each other
+type Organization struct {
+	ID        MOID      `json:"id" bson:"_id,omitempty"`
+	Name      string    `json:"name" bson:"name"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+type UserOrderField string
+
+const (
+	UserOrderByID        UserOrderField = "_id"
+	UserOrderByCreatedAt UserOrderField = "created_at"
+)
+
+// UserFilter narrows down a user listing, zero values don't filter
+type UserFilter struct {
+	Role          string
+	EmailPrefix   string
+	NameContains  string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	TenantID      string
+	GroupID       string
+	// IncludeDeleted also matches deleted users that weren't purged yet
+	IncludeDeleted bool
+}
+
+// UserCursor is the position of a user in a listing
+type UserCursor struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserPageQuery asks the repository for one page in scan order. After and Before are
+// exclusive bounds relative to that order.
+type UserPageQuery struct {
+	Filter     UserFilter
+	OrderBy    UserOrderField
+	Descending bool
+	After      *UserCursor
+	Before     *UserCursor
+	Limit      int64
+}
+
+// UserConnectionArgs are the arguments of a Relay connection field
+type UserConnectionArgs struct {
+	First      *int
+	After      string
+	Last       *int
+	Before     string
+	Filter     UserFilter
+	OrderBy    UserOrderField
+	Descending bool
+}
+
+type UserConnection struct {
+	Edges      []UserEdge `json:"edges"`
+	PageInfo   PageInfo   `json:"pageInfo"`
+	TotalCount int64      `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   User   `json:"node"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Role is a named set of permissions, users refer to it by name
+type Role struct {
+	ID          MOID      `json:"id" bson:"_id,omitempty"`
+	Name        string    `json:"name" bson:"name"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// AuthToken is the token pair handed out on login and refresh. A login that needs a second
+// factor only carries the MFA challenge, the tokens follow once it is answered.
+type AuthToken struct {
+	Token                 string   `json:"token"`
+	RefreshToken          string   `json:"refreshToken"`
+	ExpiresIn             int64    `json:"expiresIn"`
+	MFARequired           bool     `json:"mfaRequired"`
+	MFAEnrollmentRequired bool     `json:"mfaEnrollmentRequired"`
+	MFAChallenge          string   `json:"mfaChallenge"`
+	RecoveryCodes         []string `json:"recoveryCodes"`
+}
+
+// RefreshToken is the server side record of an issued refresh token.
+// Only the hash of the token is kept so a leaked store can't be replayed.
+type RefreshToken struct {
+	TokenHash  string    `json:"token_hash"`
+	FamilyID   string    `json:"family_id"`
+	UserID     string    `json:"user_id"`
+	AuthMethod string    `json:"auth_method"`
+	IssuedAt   time.Time `json:"issued_at"`
+}
+
+// LoginFailures counts the failed logins of an email or a client ip
+type LoginFailures struct {
+	Count         int64
+	LastFailureAt time.Time
+}
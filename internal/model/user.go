@@ -8,14 +8,33 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+)
+
 type User struct {
-	ID        MOID      `json:"id" bson:"_id,omitempty"`
-	Name      string    `json:"name" bson:"name"`
-	Email     string    `json:"email" bson:"email"`
-	Password  string    `json:"password" bson:"password"`
-	Role      string    `json:"role" bson:"role"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	ID            MOID      `json:"id" bson:"_id,omitempty"`
+	Name          string    `json:"name" bson:"name"`
+	Email         string    `json:"email" bson:"email"`
+	EmailVerified bool      `json:"email_verified" bson:"email_verified"`
+	Password      string    `json:"password" bson:"password"`
+	Role          string    `json:"role" bson:"role"`
+	Status        string    `json:"status" bson:"status"`
+	MFA           MFA       `json:"mfa" bson:"mfa"`
+	TenantID      string    `json:"tenant_id" bson:"tenant_id,omitempty"`
+	GroupIDs      []string  `json:"group_ids" bson:"group_ids,omitempty"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+	// Version counts the changes to the user, users created before versions existed are at 0
+	Version   int64      `json:"version" bson:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// IsPending reports whether the account still waits for its email to be verified.
+// Users created before statuses existed have none and count as active.
+func (u User) IsPending() bool {
+	return u.Status == UserStatusPending
 }
 
 type MOID string
@@ -28,3 +47,23 @@ func (id MOID) MarshalBSONValue() (bsontype.Type, []byte, error) {
 
 	return bson.MarshalValue(p)
 }
+
+// ProfileUpdate holds the fields users may change on their own record, nil fields are left as is
+type ProfileUpdate struct {
+	Name  *string
+	Email *string
+}
+
+// EmailVerification is what a verification token stands for, a token only
+// verifies the address it was sent to
+type EmailVerification struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// Invitation lets the invited email register while registration is invite-only
+type Invitation struct {
+	Email     string `json:"email"`
+	InvitedBy string `json:"invited_by"`
+	TenantID  string `json:"tenant_id"`
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when no record matches the lookup
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicateKey is returned when a write violates a unique index
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrVersionConflict is returned when a record changed since the version a write expects
+	ErrVersionConflict = errors.New("version conflict")
+)
@@ -0,0 +1,95 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "go-graphql-user-svc/internal/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IAuditRepository is an autogenerated mock type for the IAuditRepository type
+type IAuditRepository struct {
+	mock.Mock
+}
+
+// Each provides a mock function with given fields: ctx, filter, fn
+func (_m *IAuditRepository) Each(ctx context.Context, filter model.AuditFilter, fn func(model.AuditEvent) error) error {
+	ret := _m.Called(ctx, filter, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Each")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, func(model.AuditEvent) error) error); ok {
+		r0 = rf(ctx, filter, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindPage provides a mock function with given fields: ctx, filter, afterID, limit
+func (_m *IAuditRepository) FindPage(ctx context.Context, filter model.AuditFilter, afterID string, limit int64) ([]model.AuditEvent, error) {
+	ret := _m.Called(ctx, filter, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindPage")
+	}
+
+	var r0 []model.AuditEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, string, int64) ([]model.AuditEvent, error)); ok {
+		return rf(ctx, filter, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, string, int64) []model.AuditEvent); ok {
+		r0 = rf(ctx, filter, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AuditEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.AuditFilter, string, int64) error); ok {
+		r1 = rf(ctx, filter, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Insert provides a mock function with given fields: ctx, event
+func (_m *IAuditRepository) Insert(ctx context.Context, event model.AuditEvent) error {
+	ret := _m.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Insert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditEvent) error); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIAuditRepository creates a new instance of IAuditRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIAuditRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IAuditRepository {
+	mock := &IAuditRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
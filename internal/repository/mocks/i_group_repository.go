@@ -0,0 +1,197 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "go-graphql-user-svc/internal/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IGroupRepository is an autogenerated mock type for the IGroupRepository type
+type IGroupRepository struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, group
+func (_m *IGroupRepository) Create(ctx context.Context, group model.Group) (*model.Group, error) {
+	ret := _m.Called(ctx, group)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Group) (*model.Group, error)); ok {
+		return rf(ctx, group)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.Group) *model.Group); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.Group) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *IGroupRepository) Delete(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindAll provides a mock function with given fields: ctx
+func (_m *IGroupRepository) FindAll(ctx context.Context) ([]model.Group, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAll")
+	}
+
+	var r0 []model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]model.Group, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Group); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByID provides a mock function with given fields: ctx, id
+func (_m *IGroupRepository) FindByID(ctx context.Context, id string) (*model.Group, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindByID")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.Group, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Group); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByIDs provides a mock function with given fields: ctx, ids
+func (_m *IGroupRepository) FindByIDs(ctx context.Context, ids []string) ([]model.Group, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindByIDs")
+	}
+
+	var r0 []model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]model.Group, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.Group); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateGrants provides a mock function with given fields: ctx, id, roles, permissions
+func (_m *IGroupRepository) UpdateGrants(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error) {
+	ret := _m.Called(ctx, id, roles, permissions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateGrants")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) (*model.Group, error)); ok {
+		return rf(ctx, id, roles, permissions)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) *model.Group); ok {
+		r0 = rf(ctx, id, roles, permissions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, []string) error); ok {
+		r1 = rf(ctx, id, roles, permissions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIGroupRepository creates a new instance of IGroupRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIGroupRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IGroupRepository {
+	mock := &IGroupRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -7,6 +7,8 @@ import (
 	model "go-graphql-user-svc/internal/model"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // IUserRepository is an autogenerated mock type for the IUserRepository type
@@ -14,6 +16,62 @@ type IUserRepository struct {
 	mock.Mock
 }
 
+// AddGroup provides a mock function with given fields: ctx, id, groupID
+func (_m *IUserRepository) AddGroup(ctx context.Context, id string, groupID string) (bool, error) {
+	ret := _m.Called(ctx, id, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddGroup")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, id, groupID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, id, groupID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, groupID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Count provides a mock function with given fields: ctx, filter
+func (_m *IUserRepository) Count(ctx context.Context, filter model.UserFilter) (int64, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Count")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) (int64, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter) int64); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, user
 func (_m *IUserRepository) Create(ctx context.Context, user model.User) (*model.User, error) {
 	ret := _m.Called(ctx, user)
@@ -122,6 +180,66 @@ func (_m *IUserRepository) FindByID(ctx context.Context, id string) (*model.User
 	return r0, r1
 }
 
+// FindDeletedByID provides a mock function with given fields: ctx, id
+func (_m *IUserRepository) FindDeletedByID(ctx context.Context, id string) (*model.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindDeletedByID")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPage provides a mock function with given fields: ctx, query
+func (_m *IUserRepository) FindPage(ctx context.Context, query model.UserPageQuery) ([]model.User, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindPage")
+	}
+
+	var r0 []model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserPageQuery) ([]model.User, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserPageQuery) []model.User); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserPageQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Getall provides a mock function with given fields: ctx
 func (_m *IUserRepository) Getall(ctx context.Context) *[]model.User {
 	ret := _m.Called(ctx)
@@ -142,6 +260,146 @@ func (_m *IUserRepository) Getall(ctx context.Context) *[]model.User {
 	return r0
 }
 
+// MarkEmailVerified provides a mock function with given fields: ctx, id, email
+func (_m *IUserRepository) MarkEmailVerified(ctx context.Context, id string, email string) error {
+	ret := _m.Called(ctx, id, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkEmailVerified")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Purge provides a mock function with given fields: ctx, deletedBefore
+func (_m *IUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Purge")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, deletedBefore)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RehashPassword provides a mock function with given fields: ctx, id, oldHash, newHash
+func (_m *IUserRepository) RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	ret := _m.Called(ctx, id, oldHash, newHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RehashPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, oldHash, newHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveGroup provides a mock function with given fields: ctx, id, groupID
+func (_m *IUserRepository) RemoveGroup(ctx context.Context, id string, groupID string) (bool, error) {
+	ret := _m.Called(ctx, id, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveGroup")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, id, groupID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, id, groupID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, groupID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveGroupFromAll provides a mock function with given fields: ctx, groupID
+func (_m *IUserRepository) RemoveGroupFromAll(ctx context.Context, groupID string) error {
+	ret := _m.Called(ctx, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveGroupFromAll")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, groupID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Restore provides a mock function with given fields: ctx, id
+func (_m *IUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: ctx, id, user
 func (_m *IUserRepository) Update(ctx context.Context, id string, user model.User) (*model.User, error) {
 	ret := _m.Called(ctx, id, user)
@@ -172,6 +430,78 @@ func (_m *IUserRepository) Update(ctx context.Context, id string, user model.Use
 	return r0, r1
 }
 
+// UpdateMFA provides a mock function with given fields: ctx, id, mfa
+func (_m *IUserRepository) UpdateMFA(ctx context.Context, id string, mfa model.MFA) error {
+	ret := _m.Called(ctx, id, mfa)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMFA")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.MFA) error); ok {
+		r0 = rf(ctx, id, mfa)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePassword provides a mock function with given fields: ctx, id, hashedPassword
+func (_m *IUserRepository) UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	ret := _m.Called(ctx, id, hashedPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, hashedPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseMFAStep provides a mock function with given fields: ctx, id, step
+func (_m *IUserRepository) UseMFAStep(ctx context.Context, id string, step int64) error {
+	ret := _m.Called(ctx, id, step)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseMFAStep")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, id, step)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseRecoveryCode provides a mock function with given fields: ctx, id, sealedCode
+func (_m *IUserRepository) UseRecoveryCode(ctx context.Context, id string, sealedCode string) error {
+	ret := _m.Called(ctx, id, sealedCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseRecoveryCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, sealedCode)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewIUserRepository creates a new instance of IUserRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewIUserRepository(t interface {
@@ -4,8 +4,11 @@ package mocks
 
 import (
 	context "context"
+	model "go-graphql-user-svc/internal/model"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // RedisRepository is an autogenerated mock type for the RedisRepository type
@@ -13,6 +16,304 @@ type RedisRepository struct {
 	mock.Mock
 }
 
+// ClearLoginFailures provides a mock function with given fields: ctx, key
+func (_m *RedisRepository) ClearLoginFailures(ctx context.Context, key string) error {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClearLoginFailures")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ConsumeEmailVerificationToken provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerification, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeEmailVerificationToken")
+	}
+
+	var r0 *model.EmailVerification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.EmailVerification, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.EmailVerification); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.EmailVerification)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConsumePasswordResetToken provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumePasswordResetToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteInvitation provides a mock function with given fields: ctx, tokenHash, email
+func (_m *RedisRepository) DeleteInvitation(ctx context.Context, tokenHash string, email string) error {
+	ret := _m.Called(ctx, tokenHash, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteInvitation")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, tokenHash, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteMFAChallenge provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMFAChallenge")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInvitation provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) GetInvitation(ctx context.Context, tokenHash string) (*model.Invitation, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetInvitation")
+	}
+
+	var r0 *model.Invitation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.Invitation, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Invitation); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Invitation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLoginFailures provides a mock function with given fields: ctx, key
+func (_m *RedisRepository) GetLoginFailures(ctx context.Context, key string) (*model.LoginFailures, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLoginFailures")
+	}
+
+	var r0 *model.LoginFailures
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.LoginFailures, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.LoginFailures); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.LoginFailures)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMFAChallenge provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) GetMFAChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMFAChallenge")
+	}
+
+	var r0 *model.MFAChallenge
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.MFAChallenge, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.MFAChallenge); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MFAChallenge)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPasswordResetToken provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) GetPasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPasswordResetToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRefreshFamilyHead provides a mock function with given fields: ctx, familyID
+func (_m *RedisRepository) GetRefreshFamilyHead(ctx context.Context, familyID string) (string, error) {
+	ret := _m.Called(ctx, familyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRefreshFamilyHead")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, familyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, familyID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, familyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRefreshToken provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRefreshToken")
+	}
+
+	var r0 *model.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.RefreshToken, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.RefreshToken); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetToken provides a mock function with given fields: ctx, key
 func (_m *RedisRepository) GetToken(ctx context.Context, key string) (string, error) {
 	ret := _m.Called(ctx, key)
@@ -41,6 +342,338 @@ func (_m *RedisRepository) GetToken(ctx context.Context, key string) (string, er
 	return r0, r1
 }
 
+// GetTokensValidAfter provides a mock function with given fields: ctx, userID
+func (_m *RedisRepository) GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTokensValidAfter")
+	}
+
+	var r0 time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (time.Time, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) time.Time); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsAccessTokenRevoked provides a mock function with given fields: ctx, jti, userID, issuedAt
+func (_m *RedisRepository) IsAccessTokenRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, jti, userID, issuedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsAccessTokenRevoked")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) (bool, error)); ok {
+		return rf(ctx, jti, userID, issuedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) bool); ok {
+		r0 = rf(ctx, jti, userID, issuedAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, jti, userID, issuedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsAccountLocked provides a mock function with given fields: ctx, email
+func (_m *RedisRepository) IsAccountLocked(ctx context.Context, email string) (bool, error) {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsAccountLocked")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, email)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LockAccount provides a mock function with given fields: ctx, email, duration
+func (_m *RedisRepository) LockAccount(ctx context.Context, email string, duration time.Duration) error {
+	ret := _m.Called(ctx, email, duration)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LockAccount")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = rf(ctx, email, duration)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordLoginFailure provides a mock function with given fields: ctx, key, window
+func (_m *RedisRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*model.LoginFailures, error) {
+	ret := _m.Called(ctx, key, window)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordLoginFailure")
+	}
+
+	var r0 *model.LoginFailures
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (*model.LoginFailures, error)); ok {
+		return rf(ctx, key, window)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) *model.LoginFailures); ok {
+		r0 = rf(ctx, key, window)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.LoginFailures)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, key, window)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordMFAChallengeFailure provides a mock function with given fields: ctx, tokenHash
+func (_m *RedisRepository) RecordMFAChallengeFailure(ctx context.Context, tokenHash string) (int64, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordMFAChallengeFailure")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (int64, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeAccessToken provides a mock function with given fields: ctx, jti, ttl
+func (_m *RedisRepository) RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	ret := _m.Called(ctx, jti, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAccessToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = rf(ctx, jti, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRefreshFamily provides a mock function with given fields: ctx, familyID
+func (_m *RedisRepository) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	ret := _m.Called(ctx, familyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeRefreshFamily")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, familyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeUserTokens provides a mock function with given fields: ctx, userID, revokedAt, ttl
+func (_m *RedisRepository) RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error {
+	ret := _m.Called(ctx, userID, revokedAt, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeUserTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Duration) error); ok {
+		r0 = rf(ctx, userID, revokedAt, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateRefreshToken provides a mock function with given fields: ctx, prevHash, next, ttl
+func (_m *RedisRepository) RotateRefreshToken(ctx context.Context, prevHash string, next model.RefreshToken, ttl time.Duration) (bool, error) {
+	ret := _m.Called(ctx, prevHash, next, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RotateRefreshToken")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.RefreshToken, time.Duration) (bool, error)); ok {
+		return rf(ctx, prevHash, next, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.RefreshToken, time.Duration) bool); ok {
+		r0 = rf(ctx, prevHash, next, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.RefreshToken, time.Duration) error); ok {
+		r1 = rf(ctx, prevHash, next, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StoreEmailVerificationToken provides a mock function with given fields: ctx, tokenHash, v, ttl
+func (_m *RedisRepository) StoreEmailVerificationToken(ctx context.Context, tokenHash string, v model.EmailVerification, ttl time.Duration) error {
+	ret := _m.Called(ctx, tokenHash, v, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StoreEmailVerificationToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.EmailVerification, time.Duration) error); ok {
+		r0 = rf(ctx, tokenHash, v, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StoreInvitation provides a mock function with given fields: ctx, tokenHash, inv, ttl
+func (_m *RedisRepository) StoreInvitation(ctx context.Context, tokenHash string, inv model.Invitation, ttl time.Duration) error {
+	ret := _m.Called(ctx, tokenHash, inv, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StoreInvitation")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Invitation, time.Duration) error); ok {
+		r0 = rf(ctx, tokenHash, inv, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StoreMFAChallenge provides a mock function with given fields: ctx, tokenHash, challenge, ttl
+func (_m *RedisRepository) StoreMFAChallenge(ctx context.Context, tokenHash string, challenge model.MFAChallenge, ttl time.Duration) error {
+	ret := _m.Called(ctx, tokenHash, challenge, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StoreMFAChallenge")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.MFAChallenge, time.Duration) error); ok {
+		r0 = rf(ctx, tokenHash, challenge, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StorePasswordResetToken provides a mock function with given fields: ctx, tokenHash, userID, ttl
+func (_m *RedisRepository) StorePasswordResetToken(ctx context.Context, tokenHash string, userID string, ttl time.Duration) error {
+	ret := _m.Called(ctx, tokenHash, userID, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StorePasswordResetToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) error); ok {
+		r0 = rf(ctx, tokenHash, userID, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StoreRefreshToken provides a mock function with given fields: ctx, rt, ttl
+func (_m *RedisRepository) StoreRefreshToken(ctx context.Context, rt model.RefreshToken, ttl time.Duration) error {
+	ret := _m.Called(ctx, rt, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StoreRefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.RefreshToken, time.Duration) error); ok {
+		r0 = rf(ctx, rt, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // StoreToken provides a mock function with given fields: ctx, key, token
 func (_m *RedisRepository) StoreToken(ctx context.Context, key string, token string) error {
 	ret := _m.Called(ctx, key, token)
@@ -59,6 +692,24 @@ func (_m *RedisRepository) StoreToken(ctx context.Context, key string, token str
 	return r0
 }
 
+// UnlockAccount provides a mock function with given fields: ctx, email
+func (_m *RedisRepository) UnlockAccount(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnlockAccount")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRedisRepository creates a new instance of RedisRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRedisRepository(t interface {
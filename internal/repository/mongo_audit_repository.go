@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-user-svc/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IAuditRepository only appends and reads, audit events are never updated or deleted
+type IAuditRepository interface {
+	Insert(ctx context.Context, event model.AuditEvent) error
+	FindPage(ctx context.Context, filter model.AuditFilter, afterID string, limit int64) ([]model.AuditEvent, error)
+	Each(ctx context.Context, filter model.AuditFilter, fn func(model.AuditEvent) error) error
+}
+
+type AuditRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewAuditRepository creates a new repository instance for the audit log
+func NewAuditRepository(db *mongo.Database) *AuditRepository {
+	return &AuditRepository{
+		Collection: db.Collection("audit_events"),
+	}
+}
+
+// EnsureIndexes creates the indexes the audit queries rely on
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create audit indexes: %v", err)
+	}
+	return nil
+}
+
+// Insert appends an event to the audit log
+func (r *AuditRepository) Insert(ctx context.Context, event model.AuditEvent) error {
+	if _, err := r.Collection.InsertOne(ctx, event); err != nil {
+		return fmt.Errorf("could not insert audit event: %v", err)
+	}
+	return nil
+}
+
+// FindPage retrieves up to limit events of the tenant older than afterID, newest first
+func (r *AuditRepository) FindPage(ctx context.Context, filter model.AuditFilter, afterID string, limit int64) ([]model.AuditEvent, error) {
+	query := scoped(ctx, auditFilter(filter))
+	if afterID != "" {
+		oid, err := primitive.ObjectIDFromHex(afterID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %v", err)
+		}
+		query["_id"] = bson.M{"$lt": oid}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cur, err := r.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not find audit events: %v", err)
+	}
+	events := []model.AuditEvent{}
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("could not find audit events: %v", err)
+	}
+	return events, nil
+}
+
+// Each calls fn with every event of the tenant matching the filter, oldest first, and stops
+// at the first error
+func (r *AuditRepository) Each(ctx context.Context, filter model.AuditFilter, fn func(model.AuditEvent) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := r.Collection.Find(ctx, scoped(ctx, auditFilter(filter)), opts)
+	if err != nil {
+		return fmt.Errorf("could not find audit events: %v", err)
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var event model.AuditEvent
+		if err := cur.Decode(&event); err != nil {
+			return fmt.Errorf("could not decode audit event: %v", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("could not find audit events: %v", err)
+	}
+	return nil
+}
+
+func auditFilter(f model.AuditFilter) bson.M {
+	filter := bson.M{}
+	if f.ActorID != "" {
+		filter["actor_id"] = f.ActorID
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if f.TargetID != "" {
+		filter["target_id"] = f.TargetID
+	}
+	if r := dateRange(f.Since, f.Until); r != nil {
+		filter["created_at"] = r
+	}
+	return filter
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IGroupRepository interface {
+	FindAll(ctx context.Context) ([]model.Group, error)
+	FindByID(ctx context.Context, id string) (*model.Group, error)
+	FindByIDs(ctx context.Context, ids []string) ([]model.Group, error)
+	Create(ctx context.Context, group model.Group) (*model.Group, error)
+	UpdateGrants(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type GroupRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewGroupRepository creates a new repository instance for group-related database operations
+func NewGroupRepository(db *mongo.Database) *GroupRepository {
+	return &GroupRepository{
+		Collection: db.Collection("groups"),
+	}
+}
+
+// EnsureIndexes makes group names unique within an organization
+func (r *GroupRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create group indexes: %v", err)
+	}
+	return nil
+}
+
+// FindAll retrieves every group of the tenant ordered by name
+func (r *GroupRepository) FindAll(ctx context.Context) ([]model.Group, error) {
+	cur, err := r.Collection.Find(ctx, scoped(ctx, bson.M{}), options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("could not find groups: %v", err)
+	}
+	groups := []model.Group{}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("could not find groups: %v", err)
+	}
+	return groups, nil
+}
+
+// FindByID retrieves a group of the tenant by its ID
+func (r *GroupRepository) FindByID(ctx context.Context, id string) (*model.Group, error) {
+	var group model.Group
+	oid, _ := primitive.ObjectIDFromHex(id)
+	err := r.Collection.FindOne(ctx, scoped(ctx, bson.M{"_id": oid})).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find group: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find group: %v", err)
+	}
+	return &group, nil
+}
+
+// FindByIDs retrieves the groups of the tenant with the ids ordered by name, unknown ids are skipped
+func (r *GroupRepository) FindByIDs(ctx context.Context, ids []string) ([]model.Group, error) {
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	groups := []model.Group{}
+	if len(oids) == 0 {
+		return groups, nil
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cur, err := r.Collection.Find(ctx, scoped(ctx, bson.M{"_id": bson.M{"$in": oids}}), opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not find groups: %v", err)
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("could not find groups: %v", err)
+	}
+	return groups, nil
+}
+
+// Create inserts a new group, within a tenant scope the group always belongs to that tenant
+func (r *GroupRepository) Create(ctx context.Context, group model.Group) (*model.Group, error) {
+	if tenantID, ok := TenantFromContext(ctx); ok {
+		group.TenantID = tenantID
+	}
+	timeNow := util.TimeNow()
+	group.CreatedAt = timeNow
+	group.UpdatedAt = timeNow
+	result, err := r.Collection.InsertOne(ctx, group)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not insert group: %w", ErrDuplicateKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not insert group: %v", err)
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		group.ID = model.MOID(oid.Hex())
+	}
+	return &group, nil
+}
+
+// UpdateGrants replaces the roles and permissions of a group and returns the updated group
+func (r *GroupRepository) UpdateGrants(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{
+		"$set": bson.M{
+			"roles":       roles,
+			"permissions": permissions,
+			"updated_at":  util.TimeNow(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var group model.Group
+	err := r.Collection.FindOneAndUpdate(ctx, scoped(ctx, bson.M{"_id": oid}), update, opts).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not update group: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not update group: %v", err)
+	}
+	return &group, nil
+}
+
+// Delete removes a group of the tenant by ID
+func (r *GroupRepository) Delete(ctx context.Context, id string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	res, err := r.Collection.DeleteOne(ctx, scoped(ctx, bson.M{"_id": oid}))
+	if err != nil {
+		return fmt.Errorf("could not delete group: %v", err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not delete group: %w", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IOrganizationRepository interface {
+	FindAll(ctx context.Context) ([]model.Organization, error)
+	FindByID(ctx context.Context, id string) (*model.Organization, error)
+	Create(ctx context.Context, org model.Organization) (*model.Organization, error)
+	Rename(ctx context.Context, id string, name string) (*model.Organization, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type OrganizationRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewOrganizationRepository creates a new repository instance for organization-related database operations
+func NewOrganizationRepository(db *mongo.Database) *OrganizationRepository {
+	return &OrganizationRepository{
+		Collection: db.Collection("organizations"),
+	}
+}
+
+// EnsureIndexes creates the unique index on the organization name
+func (r *OrganizationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create organization indexes: %v", err)
+	}
+	return nil
+}
+
+// FindAll retrieves every organization ordered by name
+func (r *OrganizationRepository) FindAll(ctx context.Context) ([]model.Organization, error) {
+	cur, err := r.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("could not find organizations: %v", err)
+	}
+	orgs := []model.Organization{}
+	if err := cur.All(ctx, &orgs); err != nil {
+		return nil, fmt.Errorf("could not find organizations: %v", err)
+	}
+	return orgs, nil
+}
+
+// FindByID retrieves an organization by its ID
+func (r *OrganizationRepository) FindByID(ctx context.Context, id string) (*model.Organization, error) {
+	var org model.Organization
+	oid, _ := primitive.ObjectIDFromHex(id)
+	err := r.Collection.FindOne(ctx, bson.M{"_id": oid}).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find organization: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find organization: %v", err)
+	}
+	return &org, nil
+}
+
+// Create inserts a new organization
+func (r *OrganizationRepository) Create(ctx context.Context, org model.Organization) (*model.Organization, error) {
+	timeNow := util.TimeNow()
+	org.CreatedAt = timeNow
+	org.UpdatedAt = timeNow
+	result, err := r.Collection.InsertOne(ctx, org)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not insert organization: %w", ErrDuplicateKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not insert organization: %v", err)
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		org.ID = model.MOID(oid.Hex())
+	}
+	return &org, nil
+}
+
+// Rename changes the name of an organization and returns the updated organization
+func (r *OrganizationRepository) Rename(ctx context.Context, id string, name string) (*model.Organization, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{
+		"$set": bson.M{
+			"name":       name,
+			"updated_at": util.TimeNow(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var org model.Organization
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"_id": oid}, update, opts).Decode(&org)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not update organization: %w", ErrDuplicateKey)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not update organization: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not update organization: %v", err)
+	}
+	return &org, nil
+}
+
+// Delete removes an organization by ID
+func (r *OrganizationRepository) Delete(ctx context.Context, id string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	res, err := r.Collection.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return fmt.Errorf("could not delete organization: %v", err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not delete organization: %w", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IRoleRepository interface {
+	FindAll(ctx context.Context) ([]model.Role, error)
+	FindByName(ctx context.Context, name string) (*model.Role, error)
+	Create(ctx context.Context, role model.Role) (*model.Role, error)
+	UpdatePermissions(ctx context.Context, name string, permissions []string) (*model.Role, error)
+	Delete(ctx context.Context, name string) error
+	Seed(ctx context.Context, roles []model.Role) error
+}
+
+type RoleRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewRoleRepository creates a new repository instance for role-related database operations
+func NewRoleRepository(db *mongo.Database) *RoleRepository {
+	return &RoleRepository{
+		Collection: db.Collection("roles"),
+	}
+}
+
+// EnsureIndexes creates the unique index on the role name
+func (r *RoleRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create role indexes: %v", err)
+	}
+	return nil
+}
+
+// Seed inserts the roles when the collection is still empty
+func (r *RoleRepository) Seed(ctx context.Context, roles []model.Role) error {
+	count, err := r.Collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return fmt.Errorf("could not count roles: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	for _, role := range roles {
+		if _, err := r.Create(ctx, role); err != nil && !errors.Is(err, ErrDuplicateKey) {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAll retrieves every role ordered by name
+func (r *RoleRepository) FindAll(ctx context.Context) ([]model.Role, error) {
+	cur, err := r.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("could not find roles: %v", err)
+	}
+	roles := []model.Role{}
+	if err := cur.All(ctx, &roles); err != nil {
+		return nil, fmt.Errorf("could not find roles: %v", err)
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) FindByName(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+	err := r.Collection.FindOne(ctx, bson.M{"name": name}).Decode(&role)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find role: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find role: %v", err)
+	}
+	return &role, nil
+}
+
+// Create inserts a new role
+func (r *RoleRepository) Create(ctx context.Context, role model.Role) (*model.Role, error) {
+	timeNow := util.TimeNow()
+	role.CreatedAt = timeNow
+	role.UpdatedAt = timeNow
+	result, err := r.Collection.InsertOne(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not insert role: %w", ErrDuplicateKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not insert role: %v", err)
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		role.ID = model.MOID(oid.Hex())
+	}
+	return &role, nil
+}
+
+// UpdatePermissions replaces the permissions of a role and returns the updated role
+func (r *RoleRepository) UpdatePermissions(ctx context.Context, name string, permissions []string) (*model.Role, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"permissions": permissions,
+			"updated_at":  util.TimeNow(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var role model.Role
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"name": name}, update, opts).Decode(&role)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not update role: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not update role: %v", err)
+	}
+	return &role, nil
+}
+
+// Delete removes a role by name
+func (r *RoleRepository) Delete(ctx context.Context, name string) error {
+	res, err := r.Collection.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return fmt.Errorf("could not delete role: %v", err)
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("could not delete role: %w", ErrNotFound)
+	}
+	return nil
+}
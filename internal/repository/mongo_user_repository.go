@@ -2,15 +2,19 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go-graphql-user-svc/internal/model"
 	"go-graphql-user-svc/util"
+	"regexp"
+	"time"
 
 	"log"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type IUserRepository interface {
@@ -19,7 +23,21 @@ type IUserRepository interface {
 	FindByID(ctx context.Context, id string) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
 	Update(ctx context.Context, id string, user model.User) (*model.User, error)
+	UpdatePassword(ctx context.Context, id string, hashedPassword string) error
+	RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error
+	MarkEmailVerified(ctx context.Context, id string, email string) error
+	UpdateMFA(ctx context.Context, id string, mfa model.MFA) error
+	UseMFAStep(ctx context.Context, id string, step int64) error
+	UseRecoveryCode(ctx context.Context, id string, sealedCode string) error
 	Delete(ctx context.Context, id string) error
+	FindDeletedByID(ctx context.Context, id string) (*model.User, error)
+	Restore(ctx context.Context, id string) (*model.User, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	AddGroup(ctx context.Context, id string, groupID string) (bool, error)
+	RemoveGroup(ctx context.Context, id string, groupID string) (bool, error)
+	RemoveGroupFromAll(ctx context.Context, groupID string) error
+	FindPage(ctx context.Context, query model.UserPageQuery) ([]model.User, error)
+	Count(ctx context.Context, filter model.UserFilter) (int64, error)
 }
 
 type UserRepository struct {
@@ -33,28 +51,62 @@ func NewUserRepository(db *mongo.Database) *UserRepository {
 	}
 }
 
+// EnsureIndexes creates the indexes the user queries rely on
+func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "group_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create user indexes: %v", err)
+	}
+	return nil
+}
+
+// BackfillEmailVerified marks the users created before emails were verified as verified,
+// so requiring verified emails doesn't lock them out
+func (r *UserRepository) BackfillEmailVerified(ctx context.Context) error {
+	filter := bson.M{"email_verified": bson.M{"$exists": false}}
+	if _, err := r.Collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"email_verified": true}}); err != nil {
+		return fmt.Errorf("could not backfill email_verified: %v", err)
+	}
+	return nil
+}
+
 // GetAll retrieves all user
 func (r *UserRepository) Getall(ctx context.Context) *[]model.User {
 	var users []model.User
-	var filter = bson.M{}
-	cur, err := r.Collection.Find(ctx, filter)
+	cur, err := r.Collection.Find(ctx, live(ctx, bson.M{}))
 	if err != nil {
 		log.Println(err)
+		return &users
 	}
 	err = cur.All(ctx, &users)
-	log.Println(users)
 	if err != nil {
 		log.Println(err)
 	}
 	return &users
 }
 
-// Create inserts a new user into the database
+// Create inserts a new user into the database, within a tenant scope the user always
+// joins that tenant
 func (r *UserRepository) Create(ctx context.Context, user model.User) (*model.User, error) {
+	if tenantID, ok := TenantFromContext(ctx); ok {
+		user.TenantID = tenantID
+	}
 	timeNow := util.TimeNow()
 	user.CreatedAt = timeNow
 	user.UpdatedAt = timeNow
+	user.Version = 1
 	result, err := r.Collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not insert user: %w", ErrDuplicateKey)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not insert user: %v", err)
 	}
@@ -68,7 +120,10 @@ func (r *UserRepository) Create(ctx context.Context, user model.User) (*model.Us
 func (r *UserRepository) FindByID(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
 	oid, _ := primitive.ObjectIDFromHex(id)
-	err := r.Collection.FindOne(ctx, bson.M{"_id": oid}).Decode(&user)
+	err := r.Collection.FindOne(ctx, live(ctx, bson.M{"_id": oid})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find user: %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not find user: %v", err)
 	}
@@ -77,68 +132,381 @@ func (r *UserRepository) FindByID(ctx context.Context, id string) (*model.User,
 
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	err := r.Collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	err := r.Collection.FindOne(ctx, live(ctx, bson.M{"email": email})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find user: %w", ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not find user: %v", err)
 	}
 	return &user, nil
 }
 
-// Update modifies a user's details by ID
+// Update replaces a user's details provided the user is still at user.Version and
+// returns the user as stored afterwards
 func (r *UserRepository) Update(ctx context.Context, id string, user model.User) (*model.User, error) {
-	tx, err := r.Collection.Database().Client().StartSession()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.EndSession(context.TODO())
-
-	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		oid, _ := primitive.ObjectIDFromHex(id)
-		update := bson.M{
-			"$set": bson.M{
-				"name":       user.Name,
-				"email":      user.Email,
-				"role":       user.Role,
-				"password":   user.Password,
-				"updated_at": util.TimeNow(),
-			},
-		}
-		res, errUpdate := r.Collection.UpdateOne(sessCtx, bson.M{"_id": oid}, update)
-		if errUpdate != nil || res.ModifiedCount == 0 {
-			tx.AbortTransaction(sessCtx)
-			return nil, fmt.Errorf("could not update user: %v", errUpdate)
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := live(ctx, bson.M{"_id": oid, "version": user.Version})
+	if user.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{nil, 0}}
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"name":           user.Name,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"role":           user.Role,
+			"status":         user.Status,
+			"password":       user.Password,
+			"updated_at":     util.TimeNow(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated model.User
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("could not update user: %w", ErrDuplicateKey)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// tell a stale version apart from a user that is gone
+		if _, err := r.FindByID(ctx, id); err != nil {
+			return nil, fmt.Errorf("could not update user: %w", err)
 		}
+		return nil, fmt.Errorf("could not update user: %w", ErrVersionConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not update user: %v", err)
+	}
+	return &updated, nil
+}
 
-		if res.MatchedCount > 0 {
-			user.ID = model.MOID(oid.Hex())
-		}
+// UpdatePassword replaces only the password hash of a user
+func (r *UserRepository) UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{
+		"$set": bson.M{
+			"password":   hashedPassword,
+			"updated_at": util.TimeNow(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	res, err := r.Collection.UpdateOne(ctx, live(ctx, bson.M{"_id": oid}), update)
+	if err != nil {
+		return fmt.Errorf("could not update password: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not update password: %w", ErrNotFound)
+	}
+	return nil
+}
 
-		if res.ModifiedCount == 1 {
-			errUpdate = tx.CommitTransaction(sessCtx)
-			if errUpdate != nil {
-				log.Println(errUpdate)
-				return nil, errUpdate
-			}
-		}
+// RehashPassword swaps the stored hash for an upgraded hash of the same password. It only
+// matches while the old hash is still stored, so a concurrent password change wins.
+func (r *UserRepository) RehashPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{"$set": bson.M{"password": newHash}}
+	res, err := r.Collection.UpdateOne(ctx, live(ctx, bson.M{"_id": oid, "password": oldHash}), update)
+	if err != nil {
+		return fmt.Errorf("could not rehash password: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not rehash password: %w", ErrNotFound)
+	}
+	return nil
+}
 
-		return res, nil
+// MarkEmailVerified verifies the user's email and activates a pending account,
+// provided the user still has that email
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id string, email string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{
+		"$set": bson.M{
+			"email_verified": true,
+			"status":         model.UserStatusActive,
+			"updated_at":     util.TimeNow(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	res, err := r.Collection.UpdateOne(ctx, live(ctx, bson.M{"_id": oid, "email": email}), update)
+	if err != nil {
+		return fmt.Errorf("could not verify email: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not verify email: %w", ErrNotFound)
 	}
+	return nil
+}
 
-	_, err = tx.WithTransaction(context.TODO(), callback)
+// UpdateMFA replaces the MFA state of a user
+func (r *UserRepository) UpdateMFA(ctx context.Context, id string, mfa model.MFA) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	update := bson.M{
+		"$set": bson.M{
+			"mfa":        mfa,
+			"updated_at": util.TimeNow(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	res, err := r.Collection.UpdateOne(ctx, live(ctx, bson.M{"_id": oid}), update)
 	if err != nil {
-		log.Println(err)
-		return nil, fmt.Errorf("could not update user: %v", err)
+		return fmt.Errorf("could not update mfa: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not update mfa: %w", ErrNotFound)
 	}
+	return nil
+}
 
-	return &user, nil
+// UseMFAStep records the time step of an accepted code. It only matches a later step
+// than the last one used, so a code can't be replayed.
+func (r *UserRepository) UseMFAStep(ctx context.Context, id string, step int64) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := live(ctx, bson.M{"_id": oid, "mfa.enabled": true, "mfa.last_used_step": bson.M{"$lt": step}})
+	res, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"mfa.last_used_step": step}})
+	if err != nil {
+		return fmt.Errorf("could not use mfa code: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not use mfa code: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code, it only matches while the code is still there
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id string, sealedCode string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := live(ctx, bson.M{"_id": oid, "mfa.recovery_codes": sealedCode})
+	res, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"mfa.recovery_codes": sealedCode}})
+	if err != nil {
+		return fmt.Errorf("could not use recovery code: %v", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not use recovery code: %w", ErrNotFound)
+	}
+	return nil
 }
 
-// Delete removes a user from the database by ID
+// Delete marks a user as deleted, the user is hidden from every query until restored or
+// purged. The email stays taken until then.
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	oid, _ := primitive.ObjectIDFromHex(id)
-	_, err := r.Collection.DeleteOne(ctx, bson.M{"_id": oid})
+	res, err := r.Collection.UpdateOne(ctx, live(ctx, bson.M{"_id": oid}), bson.M{"$set": bson.M{"deleted_at": util.TimeNow()}})
 	if err != nil {
 		return fmt.Errorf("could not delete user: %v", err)
 	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("could not delete user: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// FindDeletedByID retrieves a deleted user that wasn't purged yet
+func (r *UserRepository) FindDeletedByID(ctx context.Context, id string) (*model.User, error) {
+	var user model.User
+	oid, _ := primitive.ObjectIDFromHex(id)
+	err := r.Collection.FindOne(ctx, scoped(ctx, bson.M{"_id": oid, "deleted_at": bson.M{"$ne": nil}})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not find user: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not find user: %v", err)
+	}
+	return &user, nil
+}
+
+// Restore brings back a deleted user that wasn't purged yet
+func (r *UserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := scoped(ctx, bson.M{"_id": oid, "deleted_at": bson.M{"$ne": nil}})
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": util.TimeNow()},
+		"$inc":   bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var user model.User
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("could not restore user: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not restore user: %v", err)
+	}
+	return &user, nil
+}
+
+// Purge removes the users of every tenant that were deleted before deletedBefore for good
+func (r *UserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := r.Collection.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": deletedBefore}})
+	if err != nil {
+		return 0, fmt.Errorf("could not purge users: %v", err)
+	}
+	return res.DeletedCount, nil
+}
+
+// AddGroup makes the user a member of the group, changed is false when the user already
+// was a member or doesn't exist
+func (r *UserRepository) AddGroup(ctx context.Context, id string, groupID string) (bool, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := live(ctx, bson.M{"_id": oid, "group_ids": bson.M{"$ne": groupID}})
+	update := bson.M{
+		"$push": bson.M{"group_ids": groupID},
+		"$set":  bson.M{"updated_at": util.TimeNow()},
+		"$inc":  bson.M{"version": 1},
+	}
+	res, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("could not add group: %v", err)
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// RemoveGroup ends the membership of the user in the group, changed is false when the
+// user wasn't a member
+func (r *UserRepository) RemoveGroup(ctx context.Context, id string, groupID string) (bool, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := live(ctx, bson.M{"_id": oid, "group_ids": groupID})
+	update := bson.M{
+		"$pull": bson.M{"group_ids": groupID},
+		"$set":  bson.M{"updated_at": util.TimeNow()},
+		"$inc":  bson.M{"version": 1},
+	}
+	res, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("could not remove group: %v", err)
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// RemoveGroupFromAll ends every membership in the group
+func (r *UserRepository) RemoveGroupFromAll(ctx context.Context, groupID string) error {
+	_, err := r.Collection.UpdateMany(ctx, bson.M{"group_ids": groupID}, bson.M{"$pull": bson.M{"group_ids": groupID}})
+	if err != nil {
+		return fmt.Errorf("could not remove group: %v", err)
+	}
 	return nil
 }
+
+// FindPage retrieves up to query.Limit users between the query's cursors, using a range
+// on the ordered field with _id as tie breaker so the index does the paging
+func (r *UserRepository) FindPage(ctx context.Context, query model.UserPageQuery) ([]model.User, error) {
+	order := 1
+	if query.Descending {
+		order = -1
+	}
+	conditions := []bson.M{userQuery(ctx, query.Filter)}
+	if query.After != nil {
+		cond, err := cursorCondition(query.OrderBy, *query.After, query.Descending)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+	if query.Before != nil {
+		cond, err := cursorCondition(query.OrderBy, *query.Before, !query.Descending)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+
+	sort := bson.D{{Key: "_id", Value: order}}
+	if query.OrderBy != model.UserOrderByID {
+		sort = bson.D{{Key: string(query.OrderBy), Value: order}, {Key: "_id", Value: order}}
+	}
+	opts := options.Find().SetSort(sort).SetLimit(query.Limit)
+
+	cur, err := r.Collection.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not find users: %v", err)
+	}
+	users := []model.User{}
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("could not find users: %v", err)
+	}
+	return users, nil
+}
+
+// Count returns the number of users matching the filter
+func (r *UserRepository) Count(ctx context.Context, filter model.UserFilter) (int64, error) {
+	count, err := r.Collection.CountDocuments(ctx, userQuery(ctx, filter))
+	if err != nil {
+		return 0, fmt.Errorf("could not count users: %v", err)
+	}
+	return count, nil
+}
+
+// live scopes a user filter to the tenant of ctx and leaves out deleted users
+func live(ctx context.Context, filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return scoped(ctx, filter)
+}
+
+// userQuery scopes the user filter to the tenant of ctx, deleted users only match when asked for
+func userQuery(ctx context.Context, f model.UserFilter) bson.M {
+	if f.IncludeDeleted {
+		return scoped(ctx, userFilter(f))
+	}
+	return live(ctx, userFilter(f))
+}
+
+func userFilter(f model.UserFilter) bson.M {
+	filter := bson.M{}
+	if f.Role != "" {
+		filter["role"] = f.Role
+	}
+	if f.TenantID != "" {
+		filter["tenant_id"] = f.TenantID
+	}
+	if f.GroupID != "" {
+		filter["group_ids"] = f.GroupID
+	}
+	if f.EmailPrefix != "" {
+		// an anchored, case sensitive prefix can use the email index
+		filter["email"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.EmailPrefix)}
+	}
+	if f.NameContains != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(f.NameContains), "$options": "i"}
+	}
+	if r := dateRange(f.CreatedAfter, f.CreatedBefore); r != nil {
+		filter["created_at"] = r
+	}
+	if r := dateRange(f.UpdatedAfter, f.UpdatedBefore); r != nil {
+		filter["updated_at"] = r
+	}
+	return filter
+}
+
+func dateRange(after *time.Time, before *time.Time) bson.M {
+	if after == nil && before == nil {
+		return nil
+	}
+	r := bson.M{}
+	if after != nil {
+		r["$gte"] = *after
+	}
+	if before != nil {
+		r["$lt"] = *before
+	}
+	return r
+}
+
+// cursorCondition matches the users that come strictly after c when scanning in the given direction
+func cursorCondition(field model.UserOrderField, c model.UserCursor, descending bool) (bson.M, error) {
+	oid, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+	op := "$gt"
+	if descending {
+		op = "$lt"
+	}
+	if field == model.UserOrderByID {
+		return bson.M{"_id": bson.M{op: oid}}, nil
+	}
+	return bson.M{"$or": []bson.M{
+		{string(field): bson.M{op: c.CreatedAt}},
+		{string(field): c.CreatedAt, "_id": bson.M{op: oid}},
+	}}, nil
+}
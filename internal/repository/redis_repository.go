@@ -2,14 +2,66 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	refreshTokenKeyPrefix  = "refresh_token:"
+	refreshFamilyKeyPrefix = "refresh_family:"
+	refreshUserKeyPrefix   = "refresh_user:"
+	revokedJTIKeyPrefix    = "revoked_jti:"
+	tokensValidKeyPrefix   = "tokens_valid_after:"
+	passwordResetKeyPrefix = "password_reset:"
+	passwordResetUserKey   = "password_reset_user:"
+	emailVerifyKeyPrefix   = "email_verify:"
+	emailVerifyUserKey     = "email_verify_user:"
+	invitationKeyPrefix    = "invitation:"
+	invitationEmailKey     = "invitation_email:"
+	loginFailureKeyPrefix  = "login_failures:"
+	accountLockKeyPrefix   = "account_lock:"
+	mfaChallengeKeyPrefix  = "mfa_challenge:"
+)
+
 type RedisRepository interface {
 	StoreToken(ctx context.Context, key string, token string) error
 	GetToken(ctx context.Context, key string) (string, error)
+	StoreRefreshToken(ctx context.Context, rt model.RefreshToken, ttl time.Duration) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	GetRefreshFamilyHead(ctx context.Context, familyID string) (string, error)
+	RotateRefreshToken(ctx context.Context, prevHash string, next model.RefreshToken, ttl time.Duration) (bool, error)
+	RevokeRefreshFamily(ctx context.Context, familyID string) error
+	RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error
+	RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error
+	IsAccessTokenRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error)
+	GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error)
+	StorePasswordResetToken(ctx context.Context, tokenHash string, userID string, ttl time.Duration) error
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+	StoreEmailVerificationToken(ctx context.Context, tokenHash string, v model.EmailVerification, ttl time.Duration) error
+	ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerification, error)
+	StoreInvitation(ctx context.Context, tokenHash string, inv model.Invitation, ttl time.Duration) error
+	GetInvitation(ctx context.Context, tokenHash string) (*model.Invitation, error)
+	DeleteInvitation(ctx context.Context, tokenHash string, email string) error
+	GetLoginFailures(ctx context.Context, key string) (*model.LoginFailures, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*model.LoginFailures, error)
+	ClearLoginFailures(ctx context.Context, key string) error
+	LockAccount(ctx context.Context, email string, duration time.Duration) error
+	IsAccountLocked(ctx context.Context, email string) (bool, error)
+	UnlockAccount(ctx context.Context, email string) error
+	StoreMFAChallenge(ctx context.Context, tokenHash string, challenge model.MFAChallenge, ttl time.Duration) error
+	GetMFAChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error)
+	RecordMFAChallengeFailure(ctx context.Context, tokenHash string) (int64, error)
+	DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error)
 }
 
 type redisRepository struct {
@@ -31,3 +83,389 @@ func (ar *redisRepository) StoreToken(ctx context.Context, key string, token str
 func (ar *redisRepository) GetToken(ctx context.Context, key string) (string, error) {
 	return ar.RC.Get(ctx, key).Result()
 }
+
+// StoreRefreshToken saves the first token of a new family and makes it the family head
+func (ar *redisRepository) StoreRefreshToken(ctx context.Context, rt model.RefreshToken, ttl time.Duration) error {
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+	pipe := ar.RC.TxPipeline()
+	pipe.Set(ctx, refreshTokenKeyPrefix+rt.TokenHash, data, ttl)
+	pipe.Set(ctx, refreshFamilyKeyPrefix+rt.FamilyID, rt.TokenHash, ttl)
+	pipe.SAdd(ctx, refreshUserKeyPrefix+rt.UserID, rt.FamilyID)
+	pipe.Expire(ctx, refreshUserKeyPrefix+rt.UserID, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetRefreshToken looks up a refresh token record by the hash of the token
+func (ar *redisRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	data, err := ar.RC.Get(ctx, refreshTokenKeyPrefix+tokenHash).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var rt model.RefreshToken
+	if err := json.Unmarshal(data, &rt); err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// GetRefreshFamilyHead returns the hash of the only token of the family that may still be used,
+// or an empty string when the family has expired or was revoked
+func (ar *redisRepository) GetRefreshFamilyHead(ctx context.Context, familyID string) (string, error) {
+	head, err := ar.RC.Get(ctx, refreshFamilyKeyPrefix+familyID).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return head, err
+}
+
+// rotateRefreshScript moves the family head from ARGV[1] to ARGV[2] only if
+// ARGV[1] is still the head, so two concurrent refreshes can't both succeed.
+// The family stays listed for its user as long as it lives.
+var rotateRefreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[4])
+redis.call("SET", KEYS[2], ARGV[3], "PX", ARGV[4])
+redis.call("SADD", KEYS[3], ARGV[5])
+redis.call("PEXPIRE", KEYS[3], ARGV[4])
+return 1
+`)
+
+// RotateRefreshToken replaces the family head with next. It reports false when prevHash
+// was no longer the head, which means the token had already been used.
+func (ar *redisRepository) RotateRefreshToken(ctx context.Context, prevHash string, next model.RefreshToken, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(next)
+	if err != nil {
+		return false, err
+	}
+	keys := []string{
+		refreshFamilyKeyPrefix + next.FamilyID,
+		refreshTokenKeyPrefix + next.TokenHash,
+		refreshUserKeyPrefix + next.UserID,
+	}
+	res, err := rotateRefreshScript.Run(ctx, ar.RC, keys, prevHash, next.TokenHash, data, ttl.Milliseconds(), next.FamilyID).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// RevokeRefreshFamily drops the family head so no token of the family can be used again
+func (ar *redisRepository) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	return ar.RC.Del(ctx, refreshFamilyKeyPrefix+familyID).Err()
+}
+
+// RevokeAccessToken blacklists a single access token until it would have expired anyway
+func (ar *redisRepository) RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	return ar.RC.Set(ctx, revokedJTIKeyPrefix+jti, 1, ttl).Err()
+}
+
+// RevokeUserTokens rejects every access token the user got up to revokedAt
+// and revokes all of the user's refresh token families
+func (ar *redisRepository) RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, ttl time.Duration) error {
+	families, err := ar.RC.SMembers(ctx, refreshUserKeyPrefix+userID).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	keys := []string{refreshUserKeyPrefix + userID}
+	for _, familyID := range families {
+		keys = append(keys, refreshFamilyKeyPrefix+familyID)
+	}
+
+	pipe := ar.RC.TxPipeline()
+	pipe.Set(ctx, tokensValidKeyPrefix+userID, formatUnixMicro(revokedAt), ttl)
+	pipe.Del(ctx, keys...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether the token was logged out on its own
+// or was issued before a revocation of all the user's tokens
+func (ar *redisRepository) IsAccessTokenRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error) {
+	pipe := ar.RC.Pipeline()
+	var revoked *redis.IntCmd
+	if jti != "" {
+		revoked = pipe.Exists(ctx, revokedJTIKeyPrefix+jti)
+	}
+	validAfter := pipe.Get(ctx, tokensValidKeyPrefix+userID)
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	if revoked != nil && revoked.Val() > 0 {
+		return true, nil
+	}
+	if validAfter.Err() == nil {
+		cutoff, err := parseUnixMicro(validAfter.Val())
+		if err != nil {
+			return false, err
+		}
+		return issuedAt.Before(cutoff), nil
+	}
+	return false, nil
+}
+
+// GetTokensValidAfter returns when all the user's tokens were last revoked, or the zero
+// time when they weren't
+func (ar *redisRepository) GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	validAfter, err := ar.RC.Get(ctx, tokensValidKeyPrefix+userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseUnixMicro(validAfter)
+}
+
+// formatUnixMicro writes t as unix seconds with a fraction of microseconds, the
+// precision of a token's iat
+func formatUnixMicro(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// parseUnixMicro reads a time written by formatUnixMicro, or the whole unix seconds
+// revocations were stored as before
+func parseUnixMicro(s string) (time.Time, error) {
+	secs, fraction, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var usec int64
+	if fraction != "" {
+		if len(fraction) > 6 {
+			return time.Time{}, fmt.Errorf("invalid revocation time %q", s)
+		}
+		usec, err = strconv.ParseInt((fraction + "00000")[:6], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, usec*int64(time.Microsecond)), nil
+}
+
+// StorePasswordResetToken saves a reset token for the user and drops the one requested before it
+func (ar *redisRepository) StorePasswordResetToken(ctx context.Context, tokenHash string, userID string, ttl time.Duration) error {
+	return ar.storeOneTimeToken(ctx, passwordResetKeyPrefix, passwordResetUserKey, tokenHash, userID, userID, ttl)
+}
+
+// GetPasswordResetToken returns the user a reset token was issued to without using it up
+func (ar *redisRepository) GetPasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := ar.RC.Get(ctx, passwordResetKeyPrefix+tokenHash).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return userID, err
+}
+
+// ConsumePasswordResetToken deletes the reset token and returns the user it was issued to,
+// a token can therefore only be used once
+func (ar *redisRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := ar.consumeOneTimeToken(ctx, passwordResetKeyPrefix, tokenHash)
+	if err != nil {
+		return "", err
+	}
+	if err := ar.RC.Del(ctx, passwordResetUserKey+userID).Err(); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// StoreEmailVerificationToken saves a verification token and drops the one sent before it
+func (ar *redisRepository) StoreEmailVerificationToken(ctx context.Context, tokenHash string, v model.EmailVerification, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ar.storeOneTimeToken(ctx, emailVerifyKeyPrefix, emailVerifyUserKey, tokenHash, string(data), v.UserID, ttl)
+}
+
+// ConsumeEmailVerificationToken deletes the verification token and returns what it verifies
+func (ar *redisRepository) ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (*model.EmailVerification, error) {
+	data, err := ar.consumeOneTimeToken(ctx, emailVerifyKeyPrefix, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	var v model.EmailVerification
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return nil, err
+	}
+	if err := ar.RC.Del(ctx, emailVerifyUserKey+v.UserID).Err(); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// StoreInvitation saves an invitation and drops the one sent to the same email before
+func (ar *redisRepository) StoreInvitation(ctx context.Context, tokenHash string, inv model.Invitation, ttl time.Duration) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return ar.storeOneTimeToken(ctx, invitationKeyPrefix, invitationEmailKey, tokenHash, string(data), inv.Email, ttl)
+}
+
+// GetInvitation looks up an invitation without using it up
+func (ar *redisRepository) GetInvitation(ctx context.Context, tokenHash string) (*model.Invitation, error) {
+	data, err := ar.RC.Get(ctx, invitationKeyPrefix+tokenHash).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var inv model.Invitation
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// DeleteInvitation removes an invitation once it was used
+func (ar *redisRepository) DeleteInvitation(ctx context.Context, tokenHash string, email string) error {
+	return ar.RC.Del(ctx, invitationKeyPrefix+tokenHash, invitationEmailKey+email).Err()
+}
+
+// GetLoginFailures returns the failed logins counted for the key, none when it has no recent failure
+func (ar *redisRepository) GetLoginFailures(ctx context.Context, key string) (*model.LoginFailures, error) {
+	values, err := ar.RC.HMGet(ctx, loginFailureKeyPrefix+key, "count", "last").Result()
+	if err != nil {
+		return nil, err
+	}
+	failures := &model.LoginFailures{}
+	if count, ok := values[0].(string); ok {
+		if failures.Count, err = strconv.ParseInt(count, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if last, ok := values[1].(string); ok {
+		nanos, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		failures.LastFailureAt = time.Unix(0, nanos)
+	}
+	return failures, nil
+}
+
+// RecordLoginFailure counts a failed login for the key. The count is dropped once no
+// failure was recorded for window.
+func (ar *redisRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*model.LoginFailures, error) {
+	now := util.TimeNow()
+	pipe := ar.RC.TxPipeline()
+	count := pipe.HIncrBy(ctx, loginFailureKeyPrefix+key, "count", 1)
+	pipe.HSet(ctx, loginFailureKeyPrefix+key, "last", now.UnixNano())
+	pipe.Expire(ctx, loginFailureKeyPrefix+key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return &model.LoginFailures{Count: count.Val(), LastFailureAt: now}, nil
+}
+
+// ClearLoginFailures forgets the failed logins of the key
+func (ar *redisRepository) ClearLoginFailures(ctx context.Context, key string) error {
+	return ar.RC.Del(ctx, loginFailureKeyPrefix+key).Err()
+}
+
+// LockAccount refuses every login to the email for the duration
+func (ar *redisRepository) LockAccount(ctx context.Context, email string, duration time.Duration) error {
+	return ar.RC.Set(ctx, accountLockKeyPrefix+email, util.TimeNow().Unix(), duration).Err()
+}
+
+func (ar *redisRepository) IsAccountLocked(ctx context.Context, email string) (bool, error) {
+	n, err := ar.RC.Exists(ctx, accountLockKeyPrefix+email).Result()
+	return n > 0, err
+}
+
+// UnlockAccount lifts the lock of the email before it expires
+func (ar *redisRepository) UnlockAccount(ctx context.Context, email string) error {
+	return ar.RC.Del(ctx, accountLockKeyPrefix+email).Err()
+}
+
+// StoreMFAChallenge saves the challenge a login with the right password was answered with
+func (ar *redisRepository) StoreMFAChallenge(ctx context.Context, tokenHash string, challenge model.MFAChallenge, ttl time.Duration) error {
+	key := mfaChallengeKeyPrefix + tokenHash
+	pipe := ar.RC.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", challenge.UserID, "enrollment", challenge.Enrollment, "failures", challenge.Failures)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (ar *redisRepository) GetMFAChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	values, err := ar.RC.HGetAll(ctx, mfaChallengeKeyPrefix+tokenHash).Result()
+	if err != nil {
+		return nil, err
+	}
+	if values["user_id"] == "" {
+		return nil, ErrNotFound
+	}
+	challenge := &model.MFAChallenge{
+		UserID:     values["user_id"],
+		Enrollment: values["enrollment"] == "1",
+	}
+	if challenge.Failures, err = strconv.ParseInt(values["failures"], 10, 64); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// mfaFailureScript counts a failure on a challenge that still exists, an expired
+// challenge must not come back as a key without a ttl
+var mfaFailureScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+return redis.call("HINCRBY", KEYS[1], "failures", 1)
+`)
+
+// RecordMFAChallengeFailure counts a wrong code for the challenge and returns the count
+func (ar *redisRepository) RecordMFAChallengeFailure(ctx context.Context, tokenHash string) (int64, error) {
+	n, err := mfaFailureScript.Run(ctx, ar.RC, []string{mfaChallengeKeyPrefix + tokenHash}).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, ErrNotFound
+	}
+	return n, nil
+}
+
+// DeleteMFAChallenge drops the challenge and reports whether it still existed, only one
+// caller can therefore complete a challenge
+func (ar *redisRepository) DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	n, err := ar.RC.Del(ctx, mfaChallengeKeyPrefix+tokenHash).Result()
+	return n > 0, err
+}
+
+// storeOneTimeToken keeps at most one live token per owner under tokenPrefix,
+// ownerPrefix maps the owner to the hash of their latest token
+func (ar *redisRepository) storeOneTimeToken(ctx context.Context, tokenPrefix string, ownerPrefix string, tokenHash string, value string, owner string, ttl time.Duration) error {
+	prev, err := ar.RC.Get(ctx, ownerPrefix+owner).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	pipe := ar.RC.TxPipeline()
+	if prev != "" {
+		pipe.Del(ctx, tokenPrefix+prev)
+	}
+	pipe.Set(ctx, tokenPrefix+tokenHash, value, ttl)
+	pipe.Set(ctx, ownerPrefix+owner, tokenHash, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (ar *redisRepository) consumeOneTimeToken(ctx context.Context, tokenPrefix string, tokenHash string) (string, error) {
+	value, err := ar.RC.GetDel(ctx, tokenPrefix+tokenHash).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixMicro(t *testing.T) {
+	revokedAt := time.Date(2024, 11, 22, 9, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "written by formatUnixMicro", value: formatUnixMicro(revokedAt), want: revokedAt.Truncate(time.Microsecond)},
+		{name: "short fraction", value: "1732267800.5", want: time.Unix(1732267800, 500000000)},
+		// revocations stored before microseconds were kept
+		{name: "whole seconds", value: "1732267800", want: time.Unix(1732267800, 0)},
+		{name: "too precise", value: "1732267800.1234567", wantErr: true},
+		{name: "not a number", value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUnixMicro(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %v, want %v", got, tt.want)
+		})
+	}
+}
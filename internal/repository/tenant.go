@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type tenantKey struct{}
+
+// WithTenant scopes the user queries made with ctx to the users of the tenant. An empty
+// tenant stands for the users who don't belong to any organization.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, &tenantID)
+}
+
+// AllTenants lifts the tenant scope of ctx, for checks that have to see every user
+func AllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, (*string)(nil))
+}
+
+// TenantFromContext returns the tenant the queries made with ctx are scoped to
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, _ := ctx.Value(tenantKey{}).(*string)
+	if tenantID == nil {
+		return "", false
+	}
+	return *tenantID, true
+}
+
+// scoped adds the tenant of ctx to a user filter
+func scoped(ctx context.Context, filter bson.M) bson.M {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return filter
+	}
+	if tenantID == "" {
+		// users created before organizations existed have no tenant_id at all
+		filter["tenant_id"] = bson.M{"$in": bson.A{nil, ""}}
+	} else {
+		filter["tenant_id"] = tenantID
+	}
+	return filter
+}
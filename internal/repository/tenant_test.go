@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScoped(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		want       bson.M
+		wantTenant string
+		wantScoped bool
+	}{
+		{
+			name: "no scope",
+			ctx:  context.Background(),
+			want: bson.M{"role": "User"},
+		},
+		{
+			name:       "tenant",
+			ctx:        WithTenant(context.Background(), "org1"),
+			want:       bson.M{"role": "User", "tenant_id": "org1"},
+			wantTenant: "org1",
+			wantScoped: true,
+		},
+		{
+			name:       "no organization",
+			ctx:        WithTenant(context.Background(), ""),
+			want:       bson.M{"role": "User", "tenant_id": bson.M{"$in": bson.A{nil, ""}}},
+			wantScoped: true,
+		},
+		{
+			name:       "inner scope wins",
+			ctx:        WithTenant(WithTenant(context.Background(), "org1"), "org2"),
+			want:       bson.M{"role": "User", "tenant_id": "org2"},
+			wantTenant: "org2",
+			wantScoped: true,
+		},
+		{
+			name: "lifted",
+			ctx:  AllTenants(WithTenant(context.Background(), "org1")),
+			want: bson.M{"role": "User"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantID, ok := TenantFromContext(tt.ctx)
+			assert.Equal(t, tt.wantScoped, ok)
+			assert.Equal(t, tt.wantTenant, tenantID)
+			assert.Equal(t, tt.want, scoped(tt.ctx, bson.M{"role": "User"}))
+		})
+	}
+}
+
+func TestScopedOverridesFilterTenant(t *testing.T) {
+	// a filter naming another organization can't reach past the scope
+	got := scoped(WithTenant(context.Background(), "org1"), bson.M{"tenant_id": "org2"})
+	assert.Equal(t, bson.M{"tenant_id": "org1"}, got)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go-graphql-user-svc/internal/audit"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"reflect"
+	"sort"
+)
+
+const redacted = "[REDACTED]"
+
+// auditSecretFields are only recorded as changed, never with their values
+var auditSecretFields = map[string]bool{"password": true, "mfa": true}
+
+// auditSkippedFields change with every write or never, they'd only add noise
+var auditSkippedFields = map[string]bool{"id": true, "created_at": true, "updated_at": true, "version": true}
+
+// AuditLogger appends events to the audit log. A nil logger records nothing.
+type AuditLogger struct {
+	Repo repository.IAuditRepository
+}
+
+// NewAuditLogger creates a logger writing to the audit repository
+func NewAuditLogger(repo repository.IAuditRepository) *AuditLogger {
+	return &AuditLogger{Repo: repo}
+}
+
+// Record completes the event with the caller, the request and the changes between before
+// and after and appends it. The action already happened, so a failed write is only logged.
+func (l *AuditLogger) Record(ctx context.Context, event model.AuditEvent, before interface{}, after interface{}) {
+	if l == nil {
+		return
+	}
+	if principal, ok := auth.FromContext(ctx); ok && event.ActorID == "" {
+		event.ActorID = principal.UserID
+	}
+	info := audit.FromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	event.RequestID = info.RequestID
+	event.Changes = auditChanges(before, after)
+	event.CreatedAt = util.TimeNow()
+	// the trail must not get lost because the client went away
+	if err := l.Repo.Insert(context.WithoutCancel(ctx), event); err != nil {
+		log.Println(err)
+	}
+}
+
+// auditChanges lists the fields that differ between the JSON forms of before and after,
+// either may be nil for records that are created or deleted
+func auditChanges(before interface{}, after interface{}) []model.AuditChange {
+	b, a := auditFields(before), auditFields(after)
+	names := make([]string, 0, len(b)+len(a))
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []model.AuditChange
+	for _, name := range names {
+		if auditSkippedFields[name] || reflect.DeepEqual(b[name], a[name]) {
+			continue
+		}
+		change := model.AuditChange{Field: name, Before: b[name], After: a[name]}
+		if auditSecretFields[name] {
+			change.Before, change.After = redactedValue(b[name]), redactedValue(a[name])
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return fields
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		log.Println(err)
+	}
+	return fields
+}
+
+func redactedValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redacted
+}
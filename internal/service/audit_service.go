@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"io"
+)
+
+type IAuditService interface {
+	GetAuditEvents(ctx context.Context, filter model.AuditFilter, first *int, after string) (*model.AuditEventConnection, error)
+	ExportAuditEvents(ctx context.Context, filter model.AuditFilter, w io.Writer) error
+}
+
+type AuditService struct {
+	Repo repository.IAuditRepository
+}
+
+// NewAuditService creates a new service instance for reading the audit log
+func NewAuditService(repo repository.IAuditRepository) *AuditService {
+	return &AuditService{Repo: repo}
+}
+
+// GetAuditEvents returns one page of the caller's organization's audit log, newest first
+func (s *AuditService) GetAuditEvents(ctx context.Context, filter model.AuditFilter, first *int, after string) (*model.AuditEventConnection, error) {
+	afterID, err := decodeAuditCursor(after)
+	if err != nil {
+		return nil, apperror.Validation("after is not a valid cursor").WithDetail("field", "after")
+	}
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, apperror.Validation(fmt.Sprintf("page size must be between 0 and %d", maxPageSize))
+	}
+
+	events, err := s.Repo.FindPage(ctx, filter, afterID, int64(limit)+1)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+
+	conn := &model.AuditEventConnection{Edges: make([]model.AuditEventEdge, 0, len(events))}
+	for _, event := range events {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(event.ID))
+		conn.Edges = append(conn.Edges, model.AuditEventEdge{Cursor: cursor, Node: event})
+	}
+	conn.PageInfo.HasNextPage = hasMore
+	conn.PageInfo.HasPreviousPage = afterID != ""
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+// ExportAuditEvents writes every matching event of the caller's organization to w as one
+// JSON object per line, oldest first
+func (s *AuditService) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	err := s.Repo.Each(ctx, filter, func(event model.AuditEvent) error {
+		return enc.Encode(event)
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+func decodeAuditCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	id := string(data)
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 24 {
+		return "", fmt.Errorf("cursor has no valid id")
+	}
+	return id, nil
+}
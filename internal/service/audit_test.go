@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/audit"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditChanges(t *testing.T) {
+	before := &model.User{ID: testUserID, Name: "Ada", Email: "ada@example.com", Password: "$2a$04$old", Role: "User", UpdatedAt: time.Unix(1, 0)}
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   []model.AuditChange
+	}{
+		{
+			name:   "changed fields",
+			before: before,
+			after:  &model.User{ID: testUserID, Name: "Ada L", Email: "ada@example.com", Password: "$2a$04$old", Role: "Admin", UpdatedAt: time.Unix(2, 0)},
+			want: []model.AuditChange{
+				{Field: "name", Before: "Ada", After: "Ada L"},
+				{Field: "role", Before: "User", After: "Admin"},
+			},
+		},
+		{
+			name:   "secrets",
+			before: before,
+			after:  &model.User{ID: testUserID, Name: "Ada", Email: "ada@example.com", Password: "$2a$04$new", Role: "User", MFA: model.MFA{Enabled: true, Secret: "sealed"}},
+			want: []model.AuditChange{
+				{Field: "mfa", Before: redacted, After: redacted},
+				{Field: "password", Before: redacted, After: redacted},
+			},
+		},
+		{
+			name:  "created",
+			after: &model.User{Name: "Ada", Password: "$2a$04$new"},
+			want: []model.AuditChange{
+				{Field: "email_verified", After: false},
+				{Field: "mfa", After: redacted},
+				{Field: "name", After: "Ada"},
+				{Field: "password", After: redacted},
+				{Field: "role", After: ""},
+				{Field: "status", After: ""},
+				{Field: "tenant_id", After: ""},
+				{Field: "email", After: ""},
+			},
+		},
+		{
+			name:   "deleted through a nil pointer",
+			before: &model.Role{Name: "Support", Permissions: []string{PermUserRead}},
+			after:  (*model.Role)(nil),
+			want: []model.AuditChange{
+				{Field: "name", Before: "Support"},
+				{Field: "permissions", Before: []interface{}{PermUserRead}},
+			},
+		},
+		{name: "nothing changed", before: before, after: before},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.want, auditChanges(tt.before, tt.after))
+		})
+	}
+}
+
+func TestAuditLoggerRecord(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	repo := mocks.NewIAuditRepository(t)
+	logger := NewAuditLogger(repo)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{UserID: "6740a6a9a5a4cf3a1c5d1e02"})
+	ctx = audit.NewContext(ctx, audit.RequestInfo{IP: "192.0.2.1", UserAgent: "curl/8.0", RequestID: "req-1"})
+	repo.On("Insert", mock.Anything, model.AuditEvent{
+		ActorID:   "6740a6a9a5a4cf3a1c5d1e02",
+		Action:    model.AuditUserUpdate,
+		TargetID:  testUserID,
+		Changes:   []model.AuditChange{{Field: "password", Before: redacted, After: redacted}},
+		IP:        "192.0.2.1",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		CreatedAt: now,
+	}).Return(nil)
+
+	logger.Record(ctx, model.AuditEvent{Action: model.AuditUserUpdate, TargetID: testUserID}, &model.User{Password: "a"}, &model.User{Password: "b"})
+}
+
+func TestAuditLoggerKeepsActor(t *testing.T) {
+	repo := mocks.NewIAuditRepository(t)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{UserID: "6740a6a9a5a4cf3a1c5d1e02"})
+	// a login is done by the user signing in, not by whoever the context names
+	repo.On("Insert", mock.Anything, mock.MatchedBy(func(event model.AuditEvent) bool {
+		return event.ActorID == testUserID
+	})).Return(nil)
+
+	NewAuditLogger(repo).Record(ctx, model.AuditEvent{Action: model.AuditLogin, ActorID: testUserID}, nil, nil)
+}
+
+func TestNilAuditLogger(t *testing.T) {
+	var logger *AuditLogger
+	assert.NotPanics(t, func() {
+		logger.Record(context.Background(), model.AuditEvent{Action: model.AuditUserDelete}, nil, nil)
+	})
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"sync"
+	"time"
+)
+
+// Permissions a role can be granted
+const (
+	PermUserRead   = "user:read"
+	PermUserWrite  = "user:write"
+	PermUserDelete = "user:delete"
+	PermRoleManage = "role:manage"
+	// PermGroupManage manages the groups of the organization and their members
+	PermGroupManage = "group:manage"
+	// PermAuditRead reads and exports the audit log of the organization
+	PermAuditRead = "audit:read"
+	// PermOrgManage manages organizations and lifts the tenant scope off the user queries
+	PermOrgManage = "organization:manage"
+)
+
+// Permissions lists every known permission
+var Permissions = []string{PermUserRead, PermUserWrite, PermUserDelete, PermRoleManage, PermGroupManage, PermAuditRead, PermOrgManage}
+
+const (
+	roleLoadTimeout = 5 * time.Second
+	// roleLoadBackoff is how long a failed load is not retried
+	roleLoadBackoff = 10 * time.Second
+)
+
+// Authorizer resolves the permissions a role and group memberships grant
+type Authorizer interface {
+	Permissions(role string, groups []string) []string
+}
+
+// RoleAuthorizer grants the permissions of the role and the groups carried in the claims.
+// Roles and groups are read from the repositories and kept for ttl, so changes made on
+// another instance show up within that time.
+type RoleAuthorizer struct {
+	repo      repository.IRoleRepository
+	groupRepo repository.IGroupRepository
+	ttl       time.Duration
+
+	mu       sync.RWMutex
+	grants   *grants
+	loadedAt time.Time
+	failedAt time.Time
+}
+
+// grants are the permissions of every role by name and of every group by id, a group has
+// those of its roles as well
+type grants struct {
+	roles  map[string][]string
+	groups map[string][]string
+}
+
+// NewRoleAuthorizer creates an authorizer backed by the roles and groups collections
+func NewRoleAuthorizer(repo repository.IRoleRepository, groupRepo repository.IGroupRepository, ttl time.Duration) *RoleAuthorizer {
+	return &RoleAuthorizer{
+		repo:      repo,
+		groupRepo: groupRepo,
+		ttl:       ttl,
+	}
+}
+
+// Permissions returns the union of the permissions of the role and of the groups
+func (a *RoleAuthorizer) Permissions(role string, groups []string) []string {
+	g := a.loadGrants()
+	if g == nil {
+		return nil
+	}
+	permissions := append([]string{}, g.roles[role]...)
+	for _, id := range groups {
+		for _, perm := range g.groups[id] {
+			if !util.IsMemberofStringSlice(permissions, perm) {
+				permissions = append(permissions, perm)
+			}
+		}
+	}
+	return permissions
+}
+
+// Invalidate drops the cached roles and groups, the next check reads them again
+func (a *RoleAuthorizer) Invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loadedAt = time.Time{}
+	a.failedAt = time.Time{}
+}
+
+func (a *RoleAuthorizer) loadGrants() *grants {
+	a.mu.RLock()
+	g, fresh := a.grants, a.isFresh()
+	a.mu.RUnlock()
+	if fresh {
+		return g
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.isFresh() {
+		return a.grants
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), roleLoadTimeout)
+	defer cancel()
+	roles, err := a.repo.FindAll(ctx)
+	if err != nil {
+		// keep answering with the grants we have rather than locking everybody out
+		log.Println(err)
+		a.failedAt = util.TimeNow()
+		return a.grants
+	}
+	// the groups of every organization, nothing scopes the background context
+	groups, err := a.groupRepo.FindAll(ctx)
+	if err != nil {
+		log.Println(err)
+		a.failedAt = util.TimeNow()
+		return a.grants
+	}
+	g = &grants{
+		roles:  make(map[string][]string, len(roles)),
+		groups: make(map[string][]string, len(groups)),
+	}
+	for _, role := range roles {
+		g.roles[role.Name] = role.Permissions
+	}
+	for _, group := range groups {
+		permissions := append([]string{}, group.Permissions...)
+		for _, role := range group.Roles {
+			permissions = append(permissions, g.roles[role]...)
+		}
+		g.groups[string(group.ID)] = permissions
+	}
+	a.grants = g
+	a.loadedAt = util.TimeNow()
+	a.failedAt = time.Time{}
+	return a.grants
+}
+
+// isFresh reports whether the grants needn't be loaded again yet, after a failed load
+// every request would otherwise wait on the write lock for the repositories to answer
+func (a *RoleAuthorizer) isFresh() bool {
+	now := util.TimeNow()
+	if now.Sub(a.failedAt) < roleLoadBackoff {
+		return true
+	}
+	return a.grants != nil && now.Sub(a.loadedAt) < a.ttl
+}
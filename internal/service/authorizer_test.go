@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	testRoles = []model.Role{
+		{Name: "Admin", Permissions: []string{PermUserRead, PermUserWrite, PermRoleManage}},
+		{Name: "User", Permissions: []string{PermUserRead}},
+		{Name: "Support", Permissions: []string{PermUserWrite}},
+	}
+	testGroups = []model.Group{
+		{ID: "6740a6a9a5a4cf3a1c5d1e0a", Permissions: []string{PermGroupManage}},
+		{ID: "6740a6a9a5a4cf3a1c5d1e0b", Roles: []string{"Support"}, Permissions: []string{PermUserRead}},
+	}
+)
+
+func TestRoleAuthorizerPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		groups []string
+		want   []string
+	}{
+		{name: "role", role: "Admin", want: []string{PermUserRead, PermUserWrite, PermRoleManage}},
+		{name: "unknown role", role: "Nobody"},
+		{name: "no role"},
+		{name: "group", role: "User", groups: []string{"6740a6a9a5a4cf3a1c5d1e0a"}, want: []string{PermUserRead, PermGroupManage}},
+		{name: "roles of a group, without duplicates", role: "User", groups: []string{"6740a6a9a5a4cf3a1c5d1e0b"}, want: []string{PermUserRead, PermUserWrite}},
+		{name: "deleted group", role: "User", groups: []string{"6740a6a9a5a4cf3a1c5d1e0f"}, want: []string{PermUserRead}},
+	}
+	roles := mocks.NewIRoleRepository(t)
+	groups := mocks.NewIGroupRepository(t)
+	roles.On("FindAll", mock.Anything).Return(testRoles, nil).Once()
+	groups.On("FindAll", mock.Anything).Return(testGroups, nil).Once()
+	authz := NewRoleAuthorizer(roles, groups, time.Minute)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.want, authz.Permissions(tt.role, tt.groups))
+		})
+	}
+}
+
+func TestRoleAuthorizerCache(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	roles := mocks.NewIRoleRepository(t)
+	groups := mocks.NewIGroupRepository(t)
+	groups.On("FindAll", mock.Anything).Return([]model.Group{}, nil)
+	authz := NewRoleAuthorizer(roles, groups, time.Minute)
+
+	roles.On("FindAll", mock.Anything).Return(testRoles, nil).Once()
+	assert.Equal(t, []string{PermUserRead}, authz.Permissions("User", nil))
+
+	// within the ttl the repository isn't asked again
+	fixedNow(t, now.Add(59*time.Second))
+	assert.Equal(t, []string{PermUserRead}, authz.Permissions("User", nil))
+
+	// a change on this instance shows up right away
+	changed := []model.Role{{Name: "User", Permissions: []string{PermUserRead, PermUserDelete}}}
+	roles.On("FindAll", mock.Anything).Return(changed, nil).Once()
+	authz.Invalidate()
+	assert.Equal(t, []string{PermUserRead, PermUserDelete}, authz.Permissions("User", nil))
+
+	// one on another instance once the ttl ran out
+	roles.On("FindAll", mock.Anything).Return(testRoles, nil).Once()
+	fixedNow(t, now.Add(2*time.Minute))
+	assert.Equal(t, []string{PermUserRead}, authz.Permissions("User", nil))
+
+	// a failed reload keeps the roles it had
+	roles.On("FindAll", mock.Anything).Return(nil, errors.New("connection refused")).Once()
+	fixedNow(t, now.Add(4*time.Minute))
+	assert.Equal(t, []string{PermUserRead}, authz.Permissions("User", nil))
+
+	// and isn't retried by every request that follows
+	fixedNow(t, now.Add(4*time.Minute+roleLoadBackoff-time.Second))
+	assert.Equal(t, []string{PermUserRead}, authz.Permissions("User", nil))
+	roles.AssertNumberOfCalls(t, "FindAll", 4)
+
+	roles.On("FindAll", mock.Anything).Return(changed, nil).Once()
+	fixedNow(t, now.Add(4*time.Minute+roleLoadBackoff))
+	assert.Equal(t, []string{PermUserRead, PermUserDelete}, authz.Permissions("User", nil))
+	roles.AssertNumberOfCalls(t, "FindAll", 5)
+}
+
+func TestRoleAuthorizerNothingLoaded(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	roles := mocks.NewIRoleRepository(t)
+	groups := mocks.NewIGroupRepository(t)
+	roles.On("FindAll", mock.Anything).Return(nil, errors.New("connection refused"))
+	authz := NewRoleAuthorizer(roles, groups, time.Minute)
+
+	assert.Empty(t, authz.Permissions("Admin", nil))
+	fixedNow(t, now.Add(time.Second))
+	assert.Empty(t, authz.Permissions("Admin", nil))
+	roles.AssertNumberOfCalls(t, "FindAll", 1)
+}
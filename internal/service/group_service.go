@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"strings"
+)
+
+type IGroupService interface {
+	GetGroups(ctx context.Context) ([]model.Group, error)
+	GetGroup(ctx context.Context, id string) (*model.Group, error)
+	GetGroupsByIDs(ctx context.Context, ids []string) ([]model.Group, error)
+	CreateGroup(ctx context.Context, group model.Group) (*model.Group, error)
+	UpdateGroup(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error)
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+var (
+	errGroupNotFound = apperror.NotFound("group not found")
+	errGroupExists   = apperror.Conflict("group already exists").WithDetail("field", "name")
+)
+
+// errUnheldGrant is built per call since the detail names the permission
+func errUnheldGrant(perm string) error {
+	return apperror.Forbidden("you cannot grant permissions you don't hold").WithDetail("permission", perm)
+}
+
+type GroupService struct {
+	Repo     repository.IGroupRepository
+	RoleRepo repository.IRoleRepository
+	UserRepo repository.IUserRepository
+	Authz    *RoleAuthorizer
+	Audit    *AuditLogger
+}
+
+// NewGroupService creates a new service instance for group management
+func NewGroupService(repo repository.IGroupRepository, roleRepo repository.IRoleRepository, userRepo repository.IUserRepository, authz *RoleAuthorizer, audit *AuditLogger) *GroupService {
+	return &GroupService{
+		Repo:     repo,
+		RoleRepo: roleRepo,
+		UserRepo: userRepo,
+		Authz:    authz,
+		Audit:    audit,
+	}
+}
+
+// GetGroups returns every group of the caller's organization
+func (s *GroupService) GetGroups(ctx context.Context) ([]model.Group, error) {
+	groups, err := s.Repo.FindAll(ctx)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return groups, nil
+}
+
+// GetGroup returns the group with the id
+func (s *GroupService) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	group, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, groupLookupError(err)
+	}
+	return group, nil
+}
+
+// GetGroupsByIDs returns the groups with the ids, groups deleted in the meantime are left out
+func (s *GroupService) GetGroupsByIDs(ctx context.Context, ids []string) ([]model.Group, error) {
+	groups, err := s.Repo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return groups, nil
+}
+
+// CreateGroup adds a group to the caller's organization
+func (s *GroupService) CreateGroup(ctx context.Context, group model.Group) (*model.Group, error) {
+	name := strings.TrimSpace(group.Name)
+	if name == "" {
+		return nil, apperror.Validation("group name must not be empty").WithDetail("field", "name")
+	}
+	roles, permissions, err := s.checkGrants(ctx, group.Roles, group.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.Repo.Create(ctx, model.Group{Name: name, Roles: roles, Permissions: permissions})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, errGroupExists
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	s.Authz.Invalidate()
+	s.Audit.Record(ctx, groupAuditEvent(model.AuditGroupCreate, created), nil, created)
+	return created, nil
+}
+
+// UpdateGroup replaces the roles and permissions a group grants its members
+func (s *GroupService) UpdateGroup(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error) {
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, groupLookupError(err)
+	}
+	// nobody may change a group that grants more than they hold
+	if err := checkHeldGrants(ctx, s.RoleRepo, *current); err != nil {
+		return nil, err
+	}
+	roles, permissions, err = s.checkGrants(ctx, roles, permissions)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.Repo.UpdateGrants(ctx, id, roles, permissions)
+	if err != nil {
+		return nil, groupLookupError(err)
+	}
+	s.Authz.Invalidate()
+	s.Audit.Record(ctx, groupAuditEvent(model.AuditGroupUpdate, updated), current, updated)
+	return updated, nil
+}
+
+// DeleteGroup removes a group and ends the memberships in it
+func (s *GroupService) DeleteGroup(ctx context.Context, id string) error {
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return groupLookupError(err)
+	}
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		return groupLookupError(err)
+	}
+	s.Audit.Record(ctx, groupAuditEvent(model.AuditGroupDelete, current), current, nil)
+	// tokens may still name the group, the authorizer doesn't know it anymore
+	s.Authz.Invalidate()
+	if err := s.UserRepo.RemoveGroupFromAll(ctx, id); err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// groupAuditEvent is an event about the group in the group's organization
+func groupAuditEvent(action string, group *model.Group) model.AuditEvent {
+	return model.AuditEvent{Action: action, TargetID: string(group.ID), TenantID: group.TenantID}
+}
+
+// checkGrants validates the roles and permissions of a group, duplicates are dropped. The
+// caller has to hold everything the group grants, or managing groups would be a way to
+// raise one's own rights.
+func (s *GroupService) checkGrants(ctx context.Context, roles []string, permissions []string) ([]string, []string, error) {
+	granted := []string{}
+	for _, perm := range permissions {
+		if !util.IsMemberofStringSlice(Permissions, perm) {
+			return nil, nil, apperror.Validation("unknown permission "+perm).WithDetail("field", "permissions")
+		}
+		if !util.IsMemberofStringSlice(granted, perm) {
+			granted = append(granted, perm)
+		}
+	}
+
+	grantedRoles := []string{}
+	for _, name := range roles {
+		if util.IsMemberofStringSlice(grantedRoles, name) {
+			continue
+		}
+		_, err := s.RoleRepo.FindByName(ctx, name)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, apperror.Validation("unknown role "+name).WithDetail("field", "roles")
+		}
+		if err != nil {
+			return nil, nil, apperror.Internal(err)
+		}
+		grantedRoles = append(grantedRoles, name)
+	}
+	if err := checkHeldGrants(ctx, s.RoleRepo, model.Group{Roles: grantedRoles, Permissions: granted}); err != nil {
+		return nil, nil, err
+	}
+	return grantedRoles, granted, nil
+}
+
+// checkHeldGrants makes sure the caller holds every permission the group grants, directly
+// or through its roles
+func checkHeldGrants(ctx context.Context, roleRepo repository.IRoleRepository, group model.Group) error {
+	permissions, err := grantedPermissions(ctx, roleRepo, group)
+	if err != nil {
+		return err
+	}
+	if perm := unheldBy(ctx, permissions); perm != "" {
+		return errUnheldGrant(perm)
+	}
+	return nil
+}
+
+// grantedPermissions returns the permissions of the group and of the group's roles
+func grantedPermissions(ctx context.Context, roleRepo repository.IRoleRepository, group model.Group) ([]string, error) {
+	permissions := append([]string{}, group.Permissions...)
+	for _, name := range group.Roles {
+		role, err := roleRepo.FindByName(ctx, name)
+		if errors.Is(err, repository.ErrNotFound) {
+			// a deleted role grants nothing
+			continue
+		}
+		if err != nil {
+			return nil, apperror.Internal(err)
+		}
+		permissions = append(permissions, role.Permissions...)
+	}
+	return permissions, nil
+}
+
+// unheldBy returns the first of the permissions the caller doesn't hold, or an empty string
+func unheldBy(ctx context.Context, permissions []string) string {
+	principal, _ := auth.FromContext(ctx)
+	for _, perm := range permissions {
+		if !principal.HasPermission(perm) {
+			return perm
+		}
+	}
+	return ""
+}
+
+// groupLookupError turns a failed repository lookup into a client facing error
+func groupLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return errGroupNotFound
+	}
+	return apperror.Internal(err)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testGroupID = "6740a6a9a5a4cf3a1c5d1e0a"
+
+type groupServiceMocks struct {
+	groups *mocks.IGroupRepository
+	roles  *mocks.IRoleRepository
+	users  *mocks.IUserRepository
+}
+
+// newTestGroupService builds a service on mocks whose authorizer holds fresh grants
+func newTestGroupService(t *testing.T) (*GroupService, groupServiceMocks) {
+	t.Helper()
+	m := groupServiceMocks{
+		groups: mocks.NewIGroupRepository(t),
+		roles:  mocks.NewIRoleRepository(t),
+		users:  mocks.NewIUserRepository(t),
+	}
+	authz := NewRoleAuthorizer(m.roles, m.groups, time.Hour)
+	authz.grants, authz.loadedAt = &grants{}, time.Now()
+	return NewGroupService(m.groups, m.roles, m.users, authz, nil), m
+}
+
+// unheldPermission returns the permission an errUnheldGrant names
+func unheldPermission(t *testing.T, err error) string {
+	t.Helper()
+	var appErr *apperror.Error
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	require.Equal(t, apperror.CodeForbidden, appErr.Code)
+	return appErr.Details["permission"].(string)
+}
+
+func TestCreateGroup(t *testing.T) {
+	roles := map[string]*model.Role{
+		"Reader":  {Name: "Reader", Permissions: []string{PermUserRead}},
+		"Auditor": {Name: "Auditor", Permissions: []string{PermAuditRead}},
+	}
+	tests := []struct {
+		name        string
+		group       model.Group
+		wantGroup   model.Group
+		wantUnheld  string
+		wantInvalid bool
+	}{
+		{
+			name:      "held permissions and roles",
+			group:     model.Group{Name: " Support ", Roles: []string{"Reader", "Reader"}, Permissions: []string{PermUserWrite, PermUserWrite}},
+			wantGroup: model.Group{Name: "Support", Roles: []string{"Reader"}, Permissions: []string{PermUserWrite}},
+		},
+		{
+			name:       "permission not held",
+			group:      model.Group{Name: "Support", Permissions: []string{PermUserWrite, PermUserDelete}},
+			wantUnheld: PermUserDelete,
+		},
+		{
+			name:       "role granting a permission not held",
+			group:      model.Group{Name: "Support", Roles: []string{"Auditor"}},
+			wantUnheld: PermAuditRead,
+		},
+		{
+			name:        "unknown permission",
+			group:       model.Group{Name: "Support", Permissions: []string{"user:everything"}},
+			wantInvalid: true,
+		},
+		{
+			name:        "unknown role",
+			group:       model.Group{Name: "Support", Roles: []string{"Nobody"}},
+			wantInvalid: true,
+		},
+		{
+			name:        "no name",
+			group:       model.Group{Name: "  "},
+			wantInvalid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestGroupService(t)
+			ctx := asPrincipal("org1", PermGroupManage, PermUserRead, PermUserWrite)
+			m.roles.On("FindByName", ctx, mock.Anything).Return(func(_ context.Context, name string) (*model.Role, error) {
+				if role, ok := roles[name]; ok {
+					return role, nil
+				}
+				return nil, repository.ErrNotFound
+			}).Maybe()
+			if tt.wantUnheld == "" && !tt.wantInvalid {
+				m.groups.On("Create", ctx, tt.wantGroup).Return(&tt.wantGroup, nil)
+			}
+
+			_, err := s.CreateGroup(ctx, tt.group)
+			switch {
+			case tt.wantUnheld != "":
+				assert.Equal(t, tt.wantUnheld, unheldPermission(t, err))
+			case tt.wantInvalid:
+				assert.Equal(t, apperror.CodeValidationFailed, apperror.CodeOf(err))
+			default:
+				require.NoError(t, err)
+				assert.False(t, s.Authz.isFresh(), "a new group must show up in the grants right away")
+			}
+		})
+	}
+}
+
+func TestUpdateGroupGrantingMoreThanHeld(t *testing.T) {
+	s, m := newTestGroupService(t)
+	ctx := asPrincipal("org1", PermGroupManage, PermUserRead)
+	current := &model.Group{ID: testGroupID, TenantID: "org1", Name: "Admins", Permissions: []string{PermUserRead, PermUserDelete}}
+	m.groups.On("FindByID", ctx, testGroupID).Return(current, nil)
+
+	// shrinking the group to what the caller holds would still take it over
+	_, err := s.UpdateGroup(ctx, testGroupID, nil, []string{PermUserRead})
+	assert.Equal(t, PermUserDelete, unheldPermission(t, err))
+}
+
+func TestUpdateGroup(t *testing.T) {
+	s, m := newTestGroupService(t)
+	ctx := asPrincipal("org1", PermGroupManage, PermUserRead, PermUserWrite)
+	current := &model.Group{ID: testGroupID, TenantID: "org1", Name: "Support", Permissions: []string{PermUserRead}}
+	updated := &model.Group{ID: testGroupID, TenantID: "org1", Name: "Support", Roles: []string{}, Permissions: []string{PermUserWrite}}
+	m.groups.On("FindByID", ctx, testGroupID).Return(current, nil)
+	m.groups.On("UpdateGrants", ctx, testGroupID, []string{}, []string{PermUserWrite}).Return(updated, nil)
+
+	got, err := s.UpdateGroup(ctx, testGroupID, nil, []string{PermUserWrite})
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+	assert.False(t, s.Authz.isFresh())
+}
+
+func TestAddUserToGroup(t *testing.T) {
+	tests := []struct {
+		name       string
+		group      model.Group
+		wantUnheld string
+		wantCode   apperror.Code
+	}{
+		{
+			name:       "group grants more than the caller holds",
+			group:      model.Group{ID: testGroupID, TenantID: "org1", Permissions: []string{PermOrgManage}},
+			wantUnheld: PermOrgManage,
+		},
+		{
+			name:     "group of another organization",
+			group:    model.Group{ID: testGroupID, TenantID: "org2"},
+			wantCode: apperror.CodeForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			groups := mocks.NewIGroupRepository(t)
+			s.GroupRepo = groups
+			ctx := asPrincipal("org1", PermGroupManage)
+			user := testUser(t, s, "correct horse")
+			user.TenantID = "org1"
+			m.users.On("FindByID", ctx, testUserID).Return(user, nil)
+			m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User"}, nil)
+			groups.On("FindByID", ctx, testGroupID).Return(&tt.group, nil)
+
+			_, err := s.AddUserToGroup(ctx, testUserID, testGroupID)
+			if tt.wantUnheld != "" {
+				assert.Equal(t, tt.wantUnheld, unheldPermission(t, err))
+				return
+			}
+			assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+		})
+	}
+}
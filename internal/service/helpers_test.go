@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/auth"
+	mailermocks "go-graphql-user-svc/internal/mailer/mocks"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"go-graphql-user-svc/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testUserID = "6740a6a9a5a4cf3a1c5d1e01"
+
+type userServiceMocks struct {
+	users  *mocks.IUserRepository
+	roles  *mocks.IRoleRepository
+	orgs   *mocks.IOrganizationRepository
+	groups *mocks.IGroupRepository
+	redis  *mocks.RedisRepository
+	mail   *mailermocks.Mailer
+}
+
+// newTestUserService builds a service on mocks with a cheap bcrypt hasher and a minimal password policy
+func newTestUserService(t *testing.T) (*UserService, userServiceMocks) {
+	t.Helper()
+	hasher, err := util.NewPasswordHasher(config.PasswordHashConfig{
+		Algorithm: "bcrypt",
+		Bcrypt:    config.BcryptConfig{Cost: 4},
+	})
+	require.NoError(t, err)
+	policy, err := NewPasswordPolicy(config.PasswordPolicyConfig{MinLength: 8})
+	require.NoError(t, err)
+	m := userServiceMocks{
+		users:  mocks.NewIUserRepository(t),
+		roles:  mocks.NewIRoleRepository(t),
+		orgs:   mocks.NewIOrganizationRepository(t),
+		groups: mocks.NewIGroupRepository(t),
+		redis:  mocks.NewRedisRepository(t),
+		mail:   mailermocks.NewMailer(t),
+	}
+	cfg := &config.Config{AuthTokenConfig: config.AuthTokenConfig{Duration: 900, RefreshDuration: 3600}}
+	cfg.PasswordResetConfig.TTL = 1800
+	cfg.PasswordResetConfig.URL = "https://example.com/reset"
+	return NewUserService(m.users, m.roles, m.orgs, m.groups, m.redis, util.NewHMACKeySet("test"), hasher, nil, m.mail, policy, nil, cfg), m
+}
+
+func testUser(t *testing.T, s *UserService, password string) *model.User {
+	t.Helper()
+	hash, err := s.Hasher.Hash(password)
+	require.NoError(t, err)
+	return &model.User{
+		ID:            testUserID,
+		Name:          "Ada",
+		Email:         "ada@example.com",
+		Role:          "User",
+		Password:      hash,
+		EmailVerified: true,
+		Status:        model.UserStatusActive,
+		Version:       3,
+	}
+}
+
+// allowLogin lets every login attempt through the throttle and records what follows
+func allowLogin(m userServiceMocks) {
+	m.redis.On("IsAccountLocked", mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	m.redis.On("GetLoginFailures", mock.Anything, mock.Anything).Return(&model.LoginFailures{}, nil).Maybe()
+	m.redis.On("RecordLoginFailure", mock.Anything, mock.Anything, mock.Anything).Return(&model.LoginFailures{Count: 1}, nil).Maybe()
+	m.redis.On("ClearLoginFailures", mock.Anything, mock.Anything).Return(nil).Maybe()
+	m.redis.On("StoreRefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// fixedNow freezes the clock of the util package for the test
+func fixedNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := util.TimeNow
+	util.TimeNow = func() time.Time { return now }
+	t.Cleanup(func() { util.TimeNow = prev })
+}
+
+// asPrincipal returns a context of a caller holding the permissions
+func asPrincipal(tenantID string, permissions ...string) context.Context {
+	principal := &auth.Principal{UserID: testUserID, TenantID: tenantID, Permissions: permissions}
+	return auth.NewContext(context.Background(), principal)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+)
+
+// AddUserToGroup makes the user a member of a group of their organization. Tokens carry the
+// groups, so the user has to sign in again for the membership to count.
+func (s *UserService) AddUserToGroup(ctx context.Context, userID string, groupID string) (*model.User, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, user); err != nil {
+		return nil, err
+	}
+	group, err := s.GroupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, groupLookupError(err)
+	}
+	// callers who manage every organization aren't scoped, the group still has to match
+	if group.TenantID != user.TenantID {
+		return nil, errForeignOrganization
+	}
+	// adding someone, maybe oneself, to a group hands out what the group grants
+	if err := checkHeldGrants(ctx, s.RoleRepo, *group); err != nil {
+		return nil, err
+	}
+	changed, err := s.Repo.AddGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return s.membershipChanged(ctx, model.AuditUserAddGroup, user, changed)
+}
+
+// RemoveUserFromGroup ends the membership of the user in the group and revokes the tokens
+// that still carry it
+func (s *UserService) RemoveUserFromGroup(ctx context.Context, userID string, groupID string) (*model.User, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, user); err != nil {
+		return nil, err
+	}
+	changed, err := s.Repo.RemoveGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return s.membershipChanged(ctx, model.AuditUserRemoveGroup, user, changed)
+}
+
+func (s *UserService) membershipChanged(ctx context.Context, action string, before *model.User, changed bool) (*model.User, error) {
+	if !changed {
+		return before, nil
+	}
+	if err := s.revokeUserTokens(ctx, string(before.ID)); err != nil {
+		return nil, err
+	}
+	after, err := s.Repo.FindByID(ctx, string(before.ID))
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(action, after), before, after)
+	return after, nil
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	mfaChallengeBytes = 32
+	recoveryCodeBytes = 10
+)
+
+var (
+	errInvalidMFAChallenge = apperror.Unauthenticated("mfa challenge is invalid or expired")
+	errInvalidMFACode      = apperror.Validation("mfa code is invalid").WithDetail("field", "code")
+	errMFANotEnabled       = apperror.Validation("mfa is not enabled")
+	errMFAAlreadyEnabled   = apperror.Conflict("mfa is already enabled")
+	errMFANotStarted       = apperror.Validation("mfa enrollment has not been started")
+	errMFARequiredForRole  = apperror.Forbidden("mfa is required for your role")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// mfaRequired reports whether the user needs a second factor to sign in
+func (s *UserService) mfaRequired(user *model.User) bool {
+	return user.MFA.Enabled || util.IsMemberofStringSlice(s.Cfg.MFAConfig.RequiredRoles, user.Role)
+}
+
+// startMFAChallenge answers a login with the right password with a challenge instead of tokens
+func (s *UserService) startMFAChallenge(ctx context.Context, user *model.User) (*model.AuthToken, error) {
+	token, err := util.GenerateRandomToken(mfaChallengeBytes)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	challenge := model.MFAChallenge{UserID: string(user.ID), Enrollment: !user.MFA.Enabled}
+	ttl := s.Cfg.MFAConfig.ChallengeTTL * time.Second
+	if err := s.RedisRepo.StoreMFAChallenge(ctx, util.HashToken(token), challenge, ttl); err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return &model.AuthToken{
+		MFARequired:           true,
+		MFAEnrollmentRequired: challenge.Enrollment,
+		MFAChallenge:          token,
+	}, nil
+}
+
+// VerifyMFA answers a login challenge with a TOTP or recovery code and issues the tokens.
+// For an enrollment challenge the code confirms the new secret and the recovery codes are
+// handed out along with the tokens.
+func (s *UserService) VerifyMFA(ctx context.Context, challenge string, code string) (*model.AuthToken, error) {
+	tokenHash := util.HashToken(challenge)
+	ch, user, err := s.getMFAChallenge(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if ch.Enrollment {
+		recoveryCodes, err = s.confirmMFA(ctx, user, code)
+	} else {
+		err = s.checkMFACode(ctx, user, code)
+	}
+	if errors.Is(err, errInvalidMFACode) {
+		s.recordMFAFailure(ctx, tokenHash, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.RedisRepo.DeleteMFAChallenge(ctx, tokenHash)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	if !deleted {
+		return nil, errInvalidMFAChallenge
+	}
+	if err := s.RedisRepo.ClearLoginFailures(ctx, loginEmailKey(normalizeLoginEmail(user.Email))); err != nil {
+		log.Println(err)
+	}
+	s.recordLogin(ctx, user)
+	tokens, err := s.issueTokens(ctx, user, auth.MethodMFA, "", "")
+	if err != nil {
+		return nil, err
+	}
+	tokens.RecoveryCodes = recoveryCodes
+	return tokens, nil
+}
+
+// EnrollMFAWithChallenge starts the enrollment of a user who can't sign in without MFA
+func (s *UserService) EnrollMFAWithChallenge(ctx context.Context, challenge string) (*model.MFAEnrollment, error) {
+	ch, user, err := s.getMFAChallenge(ctx, util.HashToken(challenge))
+	if err != nil {
+		return nil, err
+	}
+	if !ch.Enrollment {
+		return nil, errMFAAlreadyEnabled
+	}
+	return s.enrollMFA(ctx, user)
+}
+
+// EnrollMFA generates a new TOTP secret for the user. It only takes effect once a code
+// of it was confirmed.
+func (s *UserService) EnrollMFA(ctx context.Context, userID string) (*model.MFAEnrollment, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	return s.enrollMFA(ctx, user)
+}
+
+// ConfirmMFA enables MFA with a code of the pending secret and returns the recovery codes,
+// they are never shown again
+func (s *UserService) ConfirmMFA(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	return s.confirmMFA(ctx, user, code)
+}
+
+// DisableMFA turns MFA off after checking a current code, unless the role requires it
+func (s *UserService) DisableMFA(ctx context.Context, userID string, code string) error {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if !user.MFA.Enabled {
+		return errMFANotEnabled
+	}
+	if util.IsMemberofStringSlice(s.Cfg.MFAConfig.RequiredRoles, user.Role) {
+		return errMFARequiredForRole
+	}
+	if err := s.checkMFACode(ctx, user, code); err != nil {
+		return err
+	}
+	if err := s.Repo.UpdateMFA(ctx, userID, model.MFA{}); err != nil {
+		return userLookupError(err)
+	}
+	s.recordSelfAction(ctx, model.AuditUserDisableMFA, user)
+	return nil
+}
+
+// ResetMFA removes the MFA of a user who lost their device and their recovery codes and ends
+// the user's sessions
+func (s *UserService) ResetMFA(ctx context.Context, userID string) error {
+	current, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, current); err != nil {
+		return err
+	}
+	if err := s.Repo.UpdateMFA(ctx, userID, model.MFA{}); err != nil {
+		return userLookupError(err)
+	}
+	updated := *current
+	updated.MFA = model.MFA{}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserResetMFA, current), current, &updated)
+	// a reset recovers a taken over account, the sessions opened with the old factor must end
+	return s.revokeUserTokens(ctx, userID)
+}
+
+func (s *UserService) getMFAChallenge(ctx context.Context, tokenHash string) (*model.MFAChallenge, *model.User, error) {
+	ch, err := s.RedisRepo.GetMFAChallenge(ctx, tokenHash)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, nil, apperror.Internal(err)
+	}
+	user, err := s.Repo.FindByID(ctx, ch.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, nil, apperror.Internal(err)
+	}
+	return ch, user, nil
+}
+
+// recordMFAFailure counts a wrong code on the challenge and as a failed login of the user,
+// a challenge is dropped after too many wrong codes
+func (s *UserService) recordMFAFailure(ctx context.Context, tokenHash string, user *model.User) {
+	s.recordLoginFailure(ctx, normalizeLoginEmail(user.Email), "")
+	failures, err := s.RedisRepo.RecordMFAChallengeFailure(ctx, tokenHash)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println(err)
+		}
+		return
+	}
+	if failures >= s.Cfg.MFAConfig.MaxAttempts {
+		if _, err := s.RedisRepo.DeleteMFAChallenge(ctx, tokenHash); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (s *UserService) enrollMFA(ctx context.Context, user *model.User) (*model.MFAEnrollment, error) {
+	if s.Secrets == nil {
+		return nil, apperror.Internal(errors.New("mfa encryption key is not configured"))
+	}
+	if user.MFA.Enabled {
+		return nil, errMFAAlreadyEnabled
+	}
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	sealed, err := s.Secrets.Seal(secret)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	if err := s.Repo.UpdateMFA(ctx, string(user.ID), model.MFA{PendingSecret: sealed}); err != nil {
+		return nil, userLookupError(err)
+	}
+	s.recordSelfAction(ctx, model.AuditUserEnrollMFA, user)
+	return &model.MFAEnrollment{
+		Secret:     secret,
+		OtpauthURI: util.TOTPURI(s.Cfg.MFAConfig.Issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *UserService) confirmMFA(ctx context.Context, user *model.User, code string) ([]string, error) {
+	if user.MFA.Enabled {
+		return nil, errMFAAlreadyEnabled
+	}
+	if user.MFA.PendingSecret == "" || s.Secrets == nil {
+		return nil, errMFANotStarted
+	}
+	secret, err := s.Secrets.Open(user.MFA.PendingSecret)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	step, ok := util.ValidateTOTP(secret, code, util.TimeNow())
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+
+	codes, sealedCodes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	mfa := model.MFA{
+		Enabled:       true,
+		Secret:        user.MFA.PendingSecret,
+		RecoveryCodes: sealedCodes,
+		LastUsedStep:  step,
+	}
+	if err := s.Repo.UpdateMFA(ctx, string(user.ID), mfa); err != nil {
+		return nil, userLookupError(err)
+	}
+	s.recordSelfAction(ctx, model.AuditUserEnableMFA, user)
+	return codes, nil
+}
+
+// checkMFACode accepts a TOTP code of a step not used before or an unused recovery code
+func (s *UserService) checkMFACode(ctx context.Context, user *model.User, code string) error {
+	if !user.MFA.Enabled {
+		return errMFANotEnabled
+	}
+	if s.Secrets == nil {
+		return apperror.Internal(errors.New("mfa encryption key is not configured"))
+	}
+	secret, err := s.Secrets.Open(user.MFA.Secret)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if step, ok := util.ValidateTOTP(secret, code, util.TimeNow()); ok {
+		err := s.Repo.UseMFAStep(ctx, string(user.ID), step)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errInvalidMFACode
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	}
+	return s.useRecoveryCode(ctx, user, code)
+}
+
+func (s *UserService) useRecoveryCode(ctx context.Context, user *model.User, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return errInvalidMFACode
+	}
+	for _, sealed := range user.MFA.RecoveryCodes {
+		plain, err := s.Secrets.Open(sealed)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(plain), []byte(code)) != 1 {
+			continue
+		}
+		err = s.Repo.UseRecoveryCode(ctx, string(user.ID), sealed)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errInvalidMFACode
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	}
+	return errInvalidMFACode
+}
+
+// generateRecoveryCodes returns the codes to show the user, formatted in groups of four,
+// and their encrypted form to store
+func (s *UserService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, s.Cfg.MFAConfig.RecoveryCodes)
+	sealedCodes := make([]string, 0, s.Cfg.MFAConfig.RecoveryCodes)
+	for i := 0; i < s.Cfg.MFAConfig.RecoveryCodes; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		sealed, err := s.Secrets.Seal(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[0:4]+"-"+code[4:8]+"-"+code[8:12]+"-"+code[12:16])
+		sealedCodes = append(sealedCodes, sealed)
+	}
+	return codes, sealedCodes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var recoveryCodeFormat = regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+
+// newTestMFAService is newTestUserService with an encryption key and four recovery codes
+func newTestMFAService(t *testing.T) (*UserService, userServiceMocks) {
+	t.Helper()
+	s, m := newTestUserService(t)
+	secrets, err := util.NewSecretBox(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	require.NoError(t, err)
+	s.Secrets = secrets
+	s.Cfg.MFAConfig.RecoveryCodes = 4
+	return s, m
+}
+
+func seal(t *testing.T, s *UserService, plain string) string {
+	t.Helper()
+	sealed, err := s.Secrets.Seal(plain)
+	require.NoError(t, err)
+	return sealed
+}
+
+func totpCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	code, err := util.TOTPCode(secret, util.TOTPStep(now))
+	require.NoError(t, err)
+	return code
+}
+
+func TestConfirmMFA(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		mfa      func(s *UserService) model.MFA
+		code     string
+		wantCode apperror.Code
+	}{
+		{
+			name: "valid code",
+			mfa:  func(s *UserService) model.MFA { return model.MFA{PendingSecret: seal(t, s, secret)} },
+			code: totpCode(t, secret, now),
+		},
+		{
+			name:     "wrong code",
+			mfa:      func(s *UserService) model.MFA { return model.MFA{PendingSecret: seal(t, s, secret)} },
+			code:     totpCode(t, secret, now.Add(-time.Hour)),
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:     "not started",
+			mfa:      func(s *UserService) model.MFA { return model.MFA{} },
+			code:     totpCode(t, secret, now),
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:     "already enabled",
+			mfa:      func(s *UserService) model.MFA { return model.MFA{Enabled: true, Secret: seal(t, s, secret)} },
+			code:     totpCode(t, secret, now),
+			wantCode: apperror.CodeConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedNow(t, now)
+			s, m := newTestMFAService(t)
+			user := testUser(t, s, "correct horse")
+			user.MFA = tt.mfa(s)
+			ctx := context.Background()
+			var stored model.MFA
+			if tt.wantCode == "" {
+				m.users.On("UpdateMFA", ctx, testUserID, mock.Anything).Run(func(args mock.Arguments) {
+					stored = args.Get(2).(model.MFA)
+				}).Return(nil)
+			}
+
+			codes, err := s.confirmMFA(ctx, user, tt.code)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, stored.Enabled)
+			assert.Equal(t, user.MFA.PendingSecret, stored.Secret)
+			assert.Empty(t, stored.PendingSecret)
+			assert.Equal(t, util.TOTPStep(now), stored.LastUsedStep, "the confirming code can't be used again")
+			require.Len(t, codes, 4)
+			require.Len(t, stored.RecoveryCodes, 4)
+			for i, code := range codes {
+				assert.Regexp(t, recoveryCodeFormat, code)
+				plain, err := s.Secrets.Open(stored.RecoveryCodes[i])
+				require.NoError(t, err)
+				assert.Equal(t, normalizeRecoveryCode(code), plain)
+			}
+		})
+	}
+}
+
+func TestCheckMFACode(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+	recovery := []string{"abcdefghijklmnop", "qrstuvwxyz234567"}
+	tests := []struct {
+		name     string
+		disabled bool
+		code     string
+		// the repository call the code leads to and what it returns
+		use      string
+		useArg   interface{}
+		useErr   error
+		wantCode apperror.Code
+	}{
+		{name: "totp", code: totpCode(t, secret, now), use: "UseMFAStep", useArg: util.TOTPStep(now)},
+		{name: "totp of the previous step", code: totpCode(t, secret, now.Add(-30*time.Second)), use: "UseMFAStep", useArg: util.TOTPStep(now) - 1},
+		{name: "replayed totp", code: totpCode(t, secret, now), use: "UseMFAStep", useArg: util.TOTPStep(now), useErr: repository.ErrNotFound, wantCode: apperror.CodeValidationFailed},
+		{name: "recovery code", code: "QRST-UVWX yz23-4567", use: "UseRecoveryCode", useArg: 1},
+		{name: "used recovery code", code: "abcd-efgh-ijkl-mnop", use: "UseRecoveryCode", useArg: 0, useErr: repository.ErrNotFound, wantCode: apperror.CodeValidationFailed},
+		{name: "unknown code", code: "zzzz-zzzz-zzzz-zzzz", wantCode: apperror.CodeValidationFailed},
+		{name: "empty code", code: " - ", wantCode: apperror.CodeValidationFailed},
+		{name: "mfa off", disabled: true, code: totpCode(t, secret, now), wantCode: apperror.CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedNow(t, now)
+			s, m := newTestMFAService(t)
+			user := testUser(t, s, "correct horse")
+			user.MFA = model.MFA{Enabled: !tt.disabled, Secret: seal(t, s, secret)}
+			for _, code := range recovery {
+				user.MFA.RecoveryCodes = append(user.MFA.RecoveryCodes, seal(t, s, code))
+			}
+			ctx := context.Background()
+			switch tt.use {
+			case "UseMFAStep":
+				m.users.On("UseMFAStep", ctx, testUserID, tt.useArg).Return(tt.useErr)
+			case "UseRecoveryCode":
+				m.users.On("UseRecoveryCode", ctx, testUserID, user.MFA.RecoveryCodes[tt.useArg.(int)]).Return(tt.useErr)
+			}
+
+			err := s.checkMFACode(ctx, user, tt.code)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestResetMFA(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	s, m := newTestMFAService(t)
+	user := testUser(t, s, "correct horse")
+	user.MFA = model.MFA{Enabled: true, Secret: seal(t, s, "secret")}
+	ctx := context.Background()
+	m.users.On("FindByID", ctx, testUserID).Return(user, nil)
+	m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User"}, nil)
+	m.users.On("UpdateMFA", ctx, testUserID, model.MFA{}).Return(nil)
+	// the sessions opened with the lost factor end with the reset
+	m.redis.On("RevokeUserTokens", ctx, testUserID, now, time.Hour).Return(nil)
+
+	assert.NoError(t, s.ResetMFA(ctx, testUserID))
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "abcdefghijklmnop", normalizeRecoveryCode("ABCD-efgh ijkl-MNOP"))
+	assert.Equal(t, "", normalizeRecoveryCode(" - - "))
+}
@@ -0,0 +1,44 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// Authorizer is an autogenerated mock type for the Authorizer type
+type Authorizer struct {
+	mock.Mock
+}
+
+// Permissions provides a mock function with given fields: role, groups
+func (_m *Authorizer) Permissions(role string, groups []string) []string {
+	ret := _m.Called(role, groups)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Permissions")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string, []string) []string); ok {
+		r0 = rf(role, groups)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// NewAuthorizer creates a new instance of Authorizer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAuthorizer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Authorizer {
+	mock := &Authorizer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,79 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+
+	model "go-graphql-user-svc/internal/model"
+)
+
+// IAuditService is an autogenerated mock type for the IAuditService type
+type IAuditService struct {
+	mock.Mock
+}
+
+// ExportAuditEvents provides a mock function with given fields: ctx, filter, w
+func (_m *IAuditService) ExportAuditEvents(ctx context.Context, filter model.AuditFilter, w io.Writer) error {
+	ret := _m.Called(ctx, filter, w)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportAuditEvents")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, io.Writer) error); ok {
+		r0 = rf(ctx, filter, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAuditEvents provides a mock function with given fields: ctx, filter, first, after
+func (_m *IAuditService) GetAuditEvents(ctx context.Context, filter model.AuditFilter, first *int, after string) (*model.AuditEventConnection, error) {
+	ret := _m.Called(ctx, filter, first, after)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAuditEvents")
+	}
+
+	var r0 *model.AuditEventConnection
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, *int, string) (*model.AuditEventConnection, error)); ok {
+		return rf(ctx, filter, first, after)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditFilter, *int, string) *model.AuditEventConnection); ok {
+		r0 = rf(ctx, filter, first, after)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuditEventConnection)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.AuditFilter, *int, string) error); ok {
+		r1 = rf(ctx, filter, first, after)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIAuditService creates a new instance of IAuditService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIAuditService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IAuditService {
+	mock := &IAuditService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,197 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "go-graphql-user-svc/internal/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IGroupService is an autogenerated mock type for the IGroupService type
+type IGroupService struct {
+	mock.Mock
+}
+
+// CreateGroup provides a mock function with given fields: ctx, group
+func (_m *IGroupService) CreateGroup(ctx context.Context, group model.Group) (*model.Group, error) {
+	ret := _m.Called(ctx, group)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateGroup")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Group) (*model.Group, error)); ok {
+		return rf(ctx, group)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.Group) *model.Group); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.Group) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteGroup provides a mock function with given fields: ctx, id
+func (_m *IGroupService) DeleteGroup(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteGroup")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetGroup provides a mock function with given fields: ctx, id
+func (_m *IGroupService) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetGroup")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.Group, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Group); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroups provides a mock function with given fields: ctx
+func (_m *IGroupService) GetGroups(ctx context.Context) ([]model.Group, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetGroups")
+	}
+
+	var r0 []model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]model.Group, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Group); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupsByIDs provides a mock function with given fields: ctx, ids
+func (_m *IGroupService) GetGroupsByIDs(ctx context.Context, ids []string) ([]model.Group, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetGroupsByIDs")
+	}
+
+	var r0 []model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]model.Group, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.Group); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateGroup provides a mock function with given fields: ctx, id, roles, permissions
+func (_m *IGroupService) UpdateGroup(ctx context.Context, id string, roles []string, permissions []string) (*model.Group, error) {
+	ret := _m.Called(ctx, id, roles, permissions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateGroup")
+	}
+
+	var r0 *model.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) (*model.Group, error)); ok {
+		return rf(ctx, id, roles, permissions)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) *model.Group); ok {
+		r0 = rf(ctx, id, roles, permissions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, []string) error); ok {
+		r1 = rf(ctx, id, roles, permissions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIGroupService creates a new instance of IGroupService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIGroupService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IGroupService {
+	mock := &IGroupService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,46 @@
+// Code generated by mockery v2.40.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	model "go-graphql-user-svc/internal/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IPasswordPolicy is an autogenerated mock type for the IPasswordPolicy type
+type IPasswordPolicy struct {
+	mock.Mock
+}
+
+// Validate provides a mock function with given fields: password, user
+func (_m *IPasswordPolicy) Validate(password string, user model.User) error {
+	ret := _m.Called(password, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Validate")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, model.User) error); ok {
+		r0 = rf(password, user)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIPasswordPolicy creates a new instance of IPasswordPolicy. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIPasswordPolicy(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IPasswordPolicy {
+	mock := &IPasswordPolicy{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -14,6 +14,84 @@ type IUserService struct {
 	mock.Mock
 }
 
+// AddUserToGroup provides a mock function with given fields: ctx, userID, groupID
+func (_m *IUserService) AddUserToGroup(ctx context.Context, userID string, groupID string) (*model.User, error) {
+	ret := _m.Called(ctx, userID, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddUserToGroup")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.User, error)); ok {
+		return rf(ctx, userID, groupID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.User); ok {
+		r0 = rf(ctx, userID, groupID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, groupID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePassword provides a mock function with given fields: ctx, userID, oldPassword, newPassword
+func (_m *IUserService) ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string) error {
+	ret := _m.Called(ctx, userID, oldPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, userID, oldPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ConfirmMFA provides a mock function with given fields: ctx, userID, code
+func (_m *IUserService) ConfirmMFA(ctx context.Context, userID string, code string) ([]string, error) {
+	ret := _m.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmMFA")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]string, error)); ok {
+		return rf(ctx, userID, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(ctx, userID, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: ctx, user
 func (_m *IUserService) CreateUser(ctx context.Context, user model.User) (*model.User, error) {
 	ret := _m.Called(ctx, user)
@@ -62,6 +140,84 @@ func (_m *IUserService) DeleteUser(ctx context.Context, id string) error {
 	return r0
 }
 
+// DisableMFA provides a mock function with given fields: ctx, userID, code
+func (_m *IUserService) DisableMFA(ctx context.Context, userID string, code string) error {
+	ret := _m.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableMFA")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnrollMFA provides a mock function with given fields: ctx, userID
+func (_m *IUserService) EnrollMFA(ctx context.Context, userID string) (*model.MFAEnrollment, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollMFA")
+	}
+
+	var r0 *model.MFAEnrollment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.MFAEnrollment, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.MFAEnrollment); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MFAEnrollment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnrollMFAWithChallenge provides a mock function with given fields: ctx, challenge
+func (_m *IUserService) EnrollMFAWithChallenge(ctx context.Context, challenge string) (*model.MFAEnrollment, error) {
+	ret := _m.Called(ctx, challenge)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollMFAWithChallenge")
+	}
+
+	var r0 *model.MFAEnrollment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.MFAEnrollment, error)); ok {
+		return rf(ctx, challenge)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.MFAEnrollment); ok {
+		r0 = rf(ctx, challenge)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MFAEnrollment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, challenge)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetAllUser provides a mock function with given fields: ctx
 func (_m *IUserService) GetAllUser(ctx context.Context) *[]model.User {
 	ret := _m.Called(ctx)
@@ -112,27 +268,203 @@ func (_m *IUserService) GetUserByID(ctx context.Context, id string) (*model.User
 	return r0, r1
 }
 
-// Login provides a mock function with given fields: ctx, user
-func (_m *IUserService) Login(ctx context.Context, user model.User) (string, error) {
-	ret := _m.Called(ctx, user)
+// GetUsersConnection provides a mock function with given fields: ctx, args
+func (_m *IUserService) GetUsersConnection(ctx context.Context, args model.UserConnectionArgs) (*model.UserConnection, error) {
+	ret := _m.Called(ctx, args)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsersConnection")
+	}
+
+	var r0 *model.UserConnection
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserConnectionArgs) (*model.UserConnection, error)); ok {
+		return rf(ctx, args)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserConnectionArgs) *model.UserConnection); ok {
+		r0 = rf(ctx, args)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserConnection)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserConnectionArgs) error); ok {
+		r1 = rf(ctx, args)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InviteUser provides a mock function with given fields: ctx, email, invitedBy
+func (_m *IUserService) InviteUser(ctx context.Context, email string, invitedBy string) error {
+	ret := _m.Called(ctx, email, invitedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InviteUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, email, invitedBy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Login provides a mock function with given fields: ctx, user, clientIP
+func (_m *IUserService) Login(ctx context.Context, user model.User, clientIP string) (*model.AuthToken, error) {
+	ret := _m.Called(ctx, user, clientIP)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Login")
 	}
 
-	var r0 string
+	var r0 *model.AuthToken
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.User) (string, error)); ok {
-		return rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, model.User, string) (*model.AuthToken, error)); ok {
+		return rf(ctx, user, clientIP)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, model.User) string); ok {
-		r0 = rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, model.User, string) *model.AuthToken); ok {
+		r0 = rf(ctx, user, clientIP)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthToken)
+		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, model.User) error); ok {
-		r1 = rf(ctx, user)
+	if rf, ok := ret.Get(1).(func(context.Context, model.User, string) error); ok {
+		r1 = rf(ctx, user, clientIP)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Logout provides a mock function with given fields: ctx, refreshToken
+func (_m *IUserService) Logout(ctx context.Context, refreshToken string) error {
+	ret := _m.Called(ctx, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Logout")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, refreshToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// LogoutAllSessions provides a mock function with given fields: ctx, userID
+func (_m *IUserService) LogoutAllSessions(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LogoutAllSessions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *IUserService) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthToken, error) {
+	ret := _m.Called(ctx, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshToken")
+	}
+
+	var r0 *model.AuthToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.AuthToken, error)); ok {
+		return rf(ctx, refreshToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.AuthToken); ok {
+		r0 = rf(ctx, refreshToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Register provides a mock function with given fields: ctx, user, inviteToken
+func (_m *IUserService) Register(ctx context.Context, user model.User, inviteToken string) (*model.User, error) {
+	ret := _m.Called(ctx, user, inviteToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Register")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.User, string) (*model.User, error)); ok {
+		return rf(ctx, user, inviteToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.User, string) *model.User); ok {
+		r0 = rf(ctx, user, inviteToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.User, string) error); ok {
+		r1 = rf(ctx, user, inviteToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveUserFromGroup provides a mock function with given fields: ctx, userID, groupID
+func (_m *IUserService) RemoveUserFromGroup(ctx context.Context, userID string, groupID string) (*model.User, error) {
+	ret := _m.Called(ctx, userID, groupID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveUserFromGroup")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.User, error)); ok {
+		return rf(ctx, userID, groupID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.User); ok {
+		r0 = rf(ctx, userID, groupID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, groupID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -140,9 +472,159 @@ func (_m *IUserService) Login(ctx context.Context, user model.User) (string, err
 	return r0, r1
 }
 
-// UpdateUser provides a mock function with given fields: ctx, id, user
-func (_m *IUserService) UpdateUser(ctx context.Context, id string, user model.User) (*model.User, error) {
-	ret := _m.Called(ctx, id, user)
+// RequestPasswordReset provides a mock function with given fields: ctx, email
+func (_m *IUserService) RequestPasswordReset(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequestPasswordReset")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetMFA provides a mock function with given fields: ctx, userID
+func (_m *IUserService) ResetMFA(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetMFA")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetPassword provides a mock function with given fields: ctx, token, newPassword
+func (_m *IUserService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	ret := _m.Called(ctx, token, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreUser provides a mock function with given fields: ctx, id
+func (_m *IUserService) RestoreUser(ctx context.Context, id string) (*model.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreUser")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SendVerificationEmail provides a mock function with given fields: ctx, email
+func (_m *IUserService) SendVerificationEmail(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendVerificationEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnlockUser provides a mock function with given fields: ctx, id
+func (_m *IUserService) UnlockUser(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnlockUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateProfile provides a mock function with given fields: ctx, id, profile, expectedVersion
+func (_m *IUserService) UpdateProfile(ctx context.Context, id string, profile model.ProfileUpdate, expectedVersion *int64) (*model.User, error) {
+	ret := _m.Called(ctx, id, profile, expectedVersion)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateProfile")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.ProfileUpdate, *int64) (*model.User, error)); ok {
+		return rf(ctx, id, profile, expectedVersion)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.ProfileUpdate, *int64) *model.User); ok {
+		r0 = rf(ctx, id, profile, expectedVersion)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.ProfileUpdate, *int64) error); ok {
+		r1 = rf(ctx, id, profile, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateUser provides a mock function with given fields: ctx, id, user, expectedVersion
+func (_m *IUserService) UpdateUser(ctx context.Context, id string, user model.User, expectedVersion *int64) (*model.User, error) {
+	ret := _m.Called(ctx, id, user, expectedVersion)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateUser")
@@ -150,19 +632,67 @@ func (_m *IUserService) UpdateUser(ctx context.Context, id string, user model.Us
 
 	var r0 *model.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, model.User) (*model.User, error)); ok {
-		return rf(ctx, id, user)
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.User, *int64) (*model.User, error)); ok {
+		return rf(ctx, id, user, expectedVersion)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, model.User) *model.User); ok {
-		r0 = rf(ctx, id, user)
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.User, *int64) *model.User); ok {
+		r0 = rf(ctx, id, user, expectedVersion)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, model.User) error); ok {
-		r1 = rf(ctx, id, user)
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.User, *int64) error); ok {
+		r1 = rf(ctx, id, user, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VerifyEmail provides a mock function with given fields: ctx, token
+func (_m *IUserService) VerifyEmail(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyMFA provides a mock function with given fields: ctx, challenge, code
+func (_m *IUserService) VerifyMFA(ctx context.Context, challenge string, code string) (*model.AuthToken, error) {
+	ret := _m.Called(ctx, challenge, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyMFA")
+	}
+
+	var r0 *model.AuthToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*model.AuthToken, error)); ok {
+		return rf(ctx, challenge, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.AuthToken); ok {
+		r0 = rf(ctx, challenge, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AuthToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, challenge, code)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"strings"
+)
+
+type IOrganizationService interface {
+	GetOrganizations(ctx context.Context) ([]model.Organization, error)
+	GetOrganization(ctx context.Context, id string) (*model.Organization, error)
+	CreateOrganization(ctx context.Context, name string) (*model.Organization, error)
+	RenameOrganization(ctx context.Context, id string, name string) (*model.Organization, error)
+	DeleteOrganization(ctx context.Context, id string) error
+}
+
+var (
+	errOrganizationNotFound = apperror.NotFound("organization not found")
+	errOrganizationExists   = apperror.Conflict("organization already exists").WithDetail("field", "name")
+	errForeignOrganization  = apperror.Forbidden("you cannot manage users of another organization")
+	errCrossTenantGrant     = apperror.Forbidden("only holders of " + PermOrgManage + " can grant it")
+	errSharedRoles          = apperror.Forbidden("roles are shared by all organizations, changing them needs " + PermOrgManage)
+)
+
+type OrganizationService struct {
+	Repo     repository.IOrganizationRepository
+	UserRepo repository.IUserRepository
+	Audit    *AuditLogger
+}
+
+// NewOrganizationService creates a new service instance for organization management
+func NewOrganizationService(repo repository.IOrganizationRepository, userRepo repository.IUserRepository, audit *AuditLogger) *OrganizationService {
+	return &OrganizationService{
+		Repo:     repo,
+		UserRepo: userRepo,
+		Audit:    audit,
+	}
+}
+
+// GetOrganizations returns every organization
+func (s *OrganizationService) GetOrganizations(ctx context.Context) ([]model.Organization, error) {
+	orgs, err := s.Repo.FindAll(ctx)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return orgs, nil
+}
+
+// GetOrganization returns the organization with the id
+func (s *OrganizationService) GetOrganization(ctx context.Context, id string) (*model.Organization, error) {
+	org, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, organizationLookupError(err)
+	}
+	return org, nil
+}
+
+// CreateOrganization adds a tenant, users join it when an admin creates or invites them into it
+func (s *OrganizationService) CreateOrganization(ctx context.Context, name string) (*model.Organization, error) {
+	name, err := organizationName(name)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.Repo.Create(ctx, model.Organization{Name: name})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, errOrganizationExists
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	s.Audit.Record(ctx, organizationAuditEvent(model.AuditOrgCreate, created), nil, created)
+	return created, nil
+}
+
+// RenameOrganization changes the name of an organization
+func (s *OrganizationService) RenameOrganization(ctx context.Context, id string, name string) (*model.Organization, error) {
+	name, err := organizationName(name)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, organizationLookupError(err)
+	}
+	updated, err := s.Repo.Rename(ctx, id, name)
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, errOrganizationExists
+	}
+	if err != nil {
+		return nil, organizationLookupError(err)
+	}
+	s.Audit.Record(ctx, organizationAuditEvent(model.AuditOrgRename, updated), current, updated)
+	return updated, nil
+}
+
+// DeleteOrganization removes an organization that has no users left
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, id string) error {
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return organizationLookupError(err)
+	}
+	// deleted users can still be restored into the organization until they are purged
+	users, err := s.UserRepo.Count(repository.AllTenants(ctx), model.UserFilter{TenantID: id, IncludeDeleted: true})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if users > 0 {
+		return apperror.Conflict("organization still has users").WithDetail("users", users)
+	}
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		return organizationLookupError(err)
+	}
+	s.Audit.Record(ctx, organizationAuditEvent(model.AuditOrgDelete, current), current, nil)
+	return nil
+}
+
+func organizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", apperror.Validation("organization name must not be empty").WithDetail("field", "name")
+	}
+	return name, nil
+}
+
+// organizationAuditEvent is an event about the organization, it belongs to the organization's own log
+func organizationAuditEvent(action string, org *model.Organization) model.AuditEvent {
+	id := string(org.ID)
+	return model.AuditEvent{Action: action, TargetID: id, TenantID: id}
+}
+
+// organizationLookupError turns a failed repository lookup into a client facing error
+func organizationLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return errOrganizationNotFound
+	}
+	return apperror.Internal(err)
+}
+
+// checkTenantGrant keeps everyone but the managers of all organizations from handing out
+// the permission to manage every organization. Belonging to no organization isn't enough.
+func checkTenantGrant(ctx context.Context, permissions []string) error {
+	principal, _ := auth.FromContext(ctx)
+	if util.IsMemberofStringSlice(permissions, PermOrgManage) && !principal.HasPermission(PermOrgManage) {
+		return errCrossTenantGrant
+	}
+	return nil
+}
+
+// checkSharedRoleChange lets only the managers of all organizations change roles, since
+// every organization uses the same roles
+func checkSharedRoleChange(ctx context.Context) error {
+	principal, _ := auth.FromContext(ctx)
+	if !principal.HasPermission(PermOrgManage) {
+		return errSharedRoles
+	}
+	return nil
+}
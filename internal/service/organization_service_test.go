@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTenantGrant(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		permissions []string
+		wantErr     bool
+	}{
+		{name: "ordinary grant", ctx: asPrincipal("org1", PermUserWrite), permissions: []string{PermUserRead}},
+		{name: "organization manager", ctx: asPrincipal("", PermOrgManage), permissions: []string{PermOrgManage}},
+		{name: "scoped admin", ctx: asPrincipal("org1", PermUserWrite, PermRoleManage), permissions: []string{PermUserRead, PermOrgManage}, wantErr: true},
+		// belonging to no organization used to count as managing all of them
+		{name: "no organization", ctx: asPrincipal("", PermUserWrite), permissions: []string{PermOrgManage}, wantErr: true},
+		{name: "anonymous", ctx: context.Background(), permissions: []string{PermOrgManage}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTenantGrant(tt.ctx, tt.permissions)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, errCrossTenantGrant, err)
+		})
+	}
+}
+
+func TestCheckSharedRoleChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{name: "organization manager", ctx: asPrincipal("", PermOrgManage)},
+		{name: "role manager of one organization", ctx: asPrincipal("org1", PermRoleManage), wantErr: true},
+		{name: "role manager without organization", ctx: asPrincipal("", PermRoleManage), wantErr: true},
+		{name: "anonymous", ctx: context.Background(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSharedRoleChange(tt.ctx)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, apperror.CodeForbidden, apperror.CodeOf(err))
+		})
+	}
+}
+
+func TestRoleChangesNeedOrganizationManage(t *testing.T) {
+	s := NewRoleService(nil, nil, nil, nil, nil)
+	ctx := asPrincipal("org1", PermRoleManage)
+
+	_, err := s.CreateRole(ctx, model.Role{Name: "Auditor", Permissions: []string{PermAuditRead}})
+	assert.Equal(t, errSharedRoles, err)
+	_, err = s.UpdateRole(ctx, "User", []string{PermUserRead})
+	assert.Equal(t, errSharedRoles, err)
+	assert.Equal(t, errSharedRoles, s.DeleteRole(ctx, "User"))
+}
+
+func TestAssignRoleAcrossTenants(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode apperror.Code
+	}{
+		{name: "organization manager", ctx: asPrincipal("", PermOrgManage, PermUserWrite)},
+		{name: "scoped admin", ctx: asPrincipal("org1", PermUserWrite), wantCode: apperror.CodeForbidden},
+		{name: "admin without organization", ctx: asPrincipal("", PermUserWrite), wantCode: apperror.CodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			m.roles.On("FindByName", tt.ctx, "Root").Return(&model.Role{Name: "Root", Permissions: []string{PermOrgManage}}, nil)
+
+			err := s.checkRole(tt.ctx, "Root")
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+		})
+	}
+}
+
+func TestCheckRoleUnknown(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := asPrincipal("", PermOrgManage)
+	m.roles.On("FindByName", ctx, "Nobody").Return(nil, repository.ErrNotFound)
+
+	assert.Equal(t, errInvalidRole, s.checkRole(ctx, "Nobody"))
+}
+
+func TestCheckTenant(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		tenantID   string
+		orgErr     error
+		wantTenant string
+		wantCode   apperror.Code
+	}{
+		{name: "scoped caller", ctx: repository.WithTenant(context.Background(), "org1"), wantTenant: "org1"},
+		{name: "scoped caller naming its organization", ctx: repository.WithTenant(context.Background(), "org1"), tenantID: "org1", wantTenant: "org1"},
+		{name: "scoped caller naming another organization", ctx: repository.WithTenant(context.Background(), "org1"), tenantID: "org2", wantCode: apperror.CodeForbidden},
+		{name: "organization manager", ctx: context.Background(), tenantID: "org2", wantTenant: "org2"},
+		{name: "unknown organization", ctx: context.Background(), tenantID: "org3", orgErr: repository.ErrNotFound, wantCode: apperror.CodeValidationFailed},
+		{name: "no organization", ctx: context.Background()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			if _, scoped := repository.TenantFromContext(tt.ctx); !scoped && tt.tenantID != "" {
+				m.orgs.On("FindByID", tt.ctx, tt.tenantID).Return(&model.Organization{ID: model.MOID(tt.tenantID)}, tt.orgErr)
+			}
+			user := &model.User{TenantID: tt.tenantID}
+
+			err := s.checkTenant(tt.ctx, user)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTenant, user.TenantID)
+		})
+	}
+}
+
+func TestDeleteOrganizationCountsDeletedUsers(t *testing.T) {
+	orgs := mocks.NewIOrganizationRepository(t)
+	users := mocks.NewIUserRepository(t)
+	s := NewOrganizationService(orgs, users, nil)
+	ctx := asPrincipal("", PermOrgManage)
+	orgs.On("FindByID", ctx, "org1").Return(&model.Organization{ID: "org1", Name: "Acme"}, nil)
+	// a deleted user can still be restored into the organization
+	users.On("Count", mock.Anything, model.UserFilter{TenantID: "org1", IncludeDeleted: true}).Return(int64(1), nil)
+
+	err := s.DeleteOrganization(ctx, "org1")
+	assert.Equal(t, apperror.CodeConflict, apperror.CodeOf(err))
+}
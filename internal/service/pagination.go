@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// GetUsersConnection returns one page of users as a Relay connection
+func (s *UserService) GetUsersConnection(ctx context.Context, args model.UserConnectionArgs) (*model.UserConnection, error) {
+	if args.First != nil && args.Last != nil {
+		return nil, apperror.Validation("first and last can't be used together")
+	}
+	after, err := decodeCursor(args.After)
+	if err != nil {
+		return nil, apperror.Validation("after is not a valid cursor").WithDetail("field", "after")
+	}
+	before, err := decodeCursor(args.Before)
+	if err != nil {
+		return nil, apperror.Validation("before is not a valid cursor").WithDetail("field", "before")
+	}
+
+	backward := args.Last != nil
+	limit := defaultPageSize
+	if args.First != nil {
+		limit = *args.First
+	} else if backward {
+		limit = *args.Last
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, apperror.Validation(fmt.Sprintf("page size must be between 0 and %d", maxPageSize))
+	}
+
+	query := model.UserPageQuery{
+		Filter:     args.Filter,
+		OrderBy:    args.OrderBy,
+		Descending: args.Descending,
+		After:      after,
+		Before:     before,
+		Limit:      int64(limit) + 1,
+	}
+	if query.OrderBy == "" {
+		query.OrderBy = model.UserOrderByCreatedAt
+	}
+	if backward {
+		// walk from before towards after and flip the page back afterwards
+		query.Descending = !query.Descending
+		query.After, query.Before = before, after
+	}
+
+	users, err := s.Repo.FindPage(ctx, query)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	total, err := s.Repo.Count(ctx, args.Filter)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+
+	conn := &model.UserConnection{
+		Edges:      make([]model.UserEdge, 0, len(users)),
+		TotalCount: total,
+	}
+	for _, user := range users {
+		conn.Edges = append(conn.Edges, model.UserEdge{Cursor: encodeCursor(user), Node: user})
+	}
+	if backward {
+		conn.PageInfo.HasPreviousPage = hasMore
+		conn.PageInfo.HasNextPage = before != nil
+	} else {
+		conn.PageInfo.HasNextPage = hasMore
+		conn.PageInfo.HasPreviousPage = after != nil
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+// encodeCursor returns an opaque cursor holding every field a listing can be ordered by
+func encodeCursor(user model.User) string {
+	data, _ := json.Marshal(model.UserCursor{ID: string(user.ID), CreatedAt: user.CreatedAt})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*model.UserCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var c model.UserCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if _, err := hex.DecodeString(c.ID); err != nil || len(c.ID) != 24 {
+		return nil, fmt.Errorf("cursor has no valid id")
+	}
+	return &c, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	user := model.User{ID: testUserID, CreatedAt: time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)}
+	got, err := decodeCursor(encodeCursor(user))
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, got.ID)
+	assert.True(t, user.CreatedAt.Equal(got.CreatedAt))
+}
+
+func TestDecodeCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name    string
+		cursor  string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty", cursor: "", wantNil: true},
+		{name: "valid", cursor: raw(`{"id":"` + testUserID + `","created_at":"2024-11-22T09:30:00Z"}`)},
+		{name: "not base64", cursor: "not a cursor!", wantErr: true},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"id":"`+testUserID+`"}`)) + "=", wantErr: true},
+		{name: "not json", cursor: raw("6740a6a9a5a4cf3a1c5d1e01"), wantErr: true},
+		{name: "missing id", cursor: raw(`{"created_at":"2024-11-22T09:30:00Z"}`), wantErr: true},
+		{name: "short id", cursor: raw(`{"id":"6740a6a9"}`), wantErr: true},
+		{name: "id not hex", cursor: raw(`{"id":"zz40a6a9a5a4cf3a1c5d1e01"}`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, got == nil)
+		})
+	}
+}
+
+func TestGetUsersConnection(t *testing.T) {
+	users := []model.User{
+		{ID: "6740a6a9a5a4cf3a1c5d1e01"},
+		{ID: "6740a6a9a5a4cf3a1c5d1e02"},
+		{ID: "6740a6a9a5a4cf3a1c5d1e03"},
+	}
+	after := encodeCursor(model.User{ID: "6740a6a9a5a4cf3a1c5d1e00"})
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		name         string
+		args         model.UserConnectionArgs
+		found        []model.User
+		wantQuery    func(q model.UserPageQuery) bool
+		wantIDs      []model.MOID
+		wantNext     bool
+		wantPrevious bool
+		wantCode     apperror.Code
+	}{
+		{
+			name:     "first and last",
+			args:     model.UserConnectionArgs{First: n(1), Last: n(1)},
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:     "page too large",
+			args:     model.UserConnectionArgs{First: n(maxPageSize + 1)},
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:     "bad cursor",
+			args:     model.UserConnectionArgs{After: "nope"},
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:  "first page with more",
+			args:  model.UserConnectionArgs{First: n(2)},
+			found: users,
+			wantQuery: func(q model.UserPageQuery) bool {
+				return q.Limit == 3 && !q.Descending && q.OrderBy == model.UserOrderByCreatedAt
+			},
+			wantIDs:  []model.MOID{users[0].ID, users[1].ID},
+			wantNext: true,
+		},
+		{
+			name:  "after a cursor",
+			args:  model.UserConnectionArgs{First: n(3), After: after},
+			found: users,
+			wantQuery: func(q model.UserPageQuery) bool {
+				return q.After != nil && q.Before == nil
+			},
+			wantIDs:      []model.MOID{users[0].ID, users[1].ID, users[2].ID},
+			wantPrevious: true,
+		},
+		{
+			name: "last page walks backwards",
+			args: model.UserConnectionArgs{Last: n(2)},
+			// the repository returns the scan order, newest first
+			found: []model.User{users[2], users[1], users[0]},
+			wantQuery: func(q model.UserPageQuery) bool {
+				return q.Limit == 3 && q.Descending
+			},
+			wantIDs:      []model.MOID{users[1].ID, users[2].ID},
+			wantPrevious: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			ctx := context.Background()
+			if tt.wantQuery != nil {
+				m.users.On("FindPage", ctx, mock.MatchedBy(tt.wantQuery)).Return(tt.found, nil)
+				m.users.On("Count", ctx, tt.args.Filter).Return(int64(len(users)), nil)
+			}
+
+			conn, err := s.GetUsersConnection(ctx, tt.args)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			var ids []model.MOID
+			for _, edge := range conn.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantNext, conn.PageInfo.HasNextPage)
+			assert.Equal(t, tt.wantPrevious, conn.PageInfo.HasPreviousPage)
+			assert.Equal(t, int64(len(users)), conn.TotalCount)
+			assert.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+		})
+	}
+}
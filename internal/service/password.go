@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"net/url"
+	"time"
+)
+
+const resetTokenBytes = 32
+
+var errInvalidResetToken = apperror.Validation("reset token is invalid or expired").WithDetail("field", "token")
+
+// ChangePassword replaces the password of the current user after checking the old one.
+// Every session of the user is revoked, including the one making the change.
+func (s *UserService) ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string) error {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if oldPassword == "" || s.Hasher.Verify(oldPassword, user.Password) != nil {
+		return apperror.Validation("old password is incorrect").WithDetail("field", "oldPassword")
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	s.recordSelfAction(ctx, model.AuditUserChangePassword, user)
+	return s.revokeUserTokens(ctx, userID)
+}
+
+// RequestPasswordReset sends a single use reset token to the user owning the email.
+// It never tells whether the email belongs to an account.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.Repo.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	token, err := util.GenerateRandomToken(resetTokenBytes)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	ttl := s.Cfg.PasswordResetConfig.TTL * time.Second
+	if err := s.RedisRepo.StorePasswordResetToken(ctx, util.HashToken(token), string(user.ID), ttl); err != nil {
+		return apperror.Internal(err)
+	}
+	// unknown emails succeed without a mail, so a failed send must look the same
+	if err := s.sendPasswordReset(ctx, user, token); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and revokes every session of the user.
+// The token is only used up once the new password passed the policy.
+func (s *UserService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	tokenHash := util.HashToken(token)
+	userID, err := s.RedisRepo.GetPasswordResetToken(ctx, tokenHash)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	user, err := s.Repo.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if err := s.Policy.Validate(newPassword, *user); err != nil {
+		return err
+	}
+
+	if _, err := s.RedisRepo.ConsumePasswordResetToken(ctx, tokenHash); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return errInvalidResetToken
+		}
+		return apperror.Internal(err)
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		if apperror.CodeOf(err) == apperror.CodeNotFound {
+			return errInvalidResetToken
+		}
+		return err
+	}
+	s.recordSelfAction(ctx, model.AuditUserResetPassword, user)
+	return s.revokeUserTokens(ctx, userID)
+}
+
+// setPassword checks the password against the policy and stores its hash
+func (s *UserService) setPassword(ctx context.Context, user *model.User, password string) error {
+	if err := s.Policy.Validate(password, *user); err != nil {
+		return err
+	}
+	hashedPassword, err := s.Hasher.Hash(password)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if err := s.Repo.UpdatePassword(ctx, string(user.ID), hashedPassword); err != nil {
+		return userLookupError(err)
+	}
+	return nil
+}
+
+// sendPasswordReset mails the user a link to the reset page carrying the token
+func (s *UserService) sendPasswordReset(ctx context.Context, user *model.User, token string) error {
+	link := s.Cfg.PasswordResetConfig.URL + "?" + url.Values{"token": {token}}.Encode()
+	return s.Mailer.Send(ctx, user.Email, mailer.TemplatePasswordReset, mailer.PasswordResetData{
+		Name:         user.Name,
+		URL:          link,
+		ValidMinutes: int(s.Cfg.PasswordResetConfig.TTL / 60),
+	})
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy violation codes, reported in extensions.violations
+const (
+	ViolationTooShort         = "TOO_SHORT"
+	ViolationTooLong          = "TOO_LONG"
+	ViolationMissingUpper     = "MISSING_UPPERCASE"
+	ViolationMissingLower     = "MISSING_LOWERCASE"
+	ViolationMissingDigit     = "MISSING_DIGIT"
+	ViolationMissingSymbol    = "MISSING_SYMBOL"
+	ViolationContainsUser     = "CONTAINS_USER_INFO"
+	ViolationCommonPassword   = "COMMON_PASSWORD"
+	ViolationBreachedPassword = "BREACHED_PASSWORD"
+)
+
+const minUserInfoLength = 3
+
+type IPasswordPolicy interface {
+	Validate(password string, user model.User) error
+}
+
+type PasswordPolicy struct {
+	cfg    config.PasswordPolicyConfig
+	common map[string]struct{}
+}
+
+// NewPasswordPolicy creates the policy and loads the common passwords list
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{
+		cfg:    cfg,
+		common: make(map[string]struct{}),
+	}
+	if cfg.CommonPasswordsFile == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(cfg.CommonPasswordsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load common passwords: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if cfg.CommonPasswordsLimit > 0 && len(p.common) >= cfg.CommonPasswordsLimit {
+			break
+		}
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.common[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not load common passwords: %v", err)
+	}
+	return p, nil
+}
+
+// Validate checks the password the user wants to set and reports every rule it breaks
+func (p *PasswordPolicy) Validate(password string, user model.User) error {
+	var violations []string
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength || length == 0 {
+		violations = append(violations, ViolationTooShort)
+	}
+	if p.cfg.MaxLength > 0 && length > p.cfg.MaxLength {
+		violations = append(violations, ViolationTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, ViolationMissingUpper)
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, ViolationMissingLower)
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, ViolationMissingDigit)
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, ViolationMissingSymbol)
+	}
+
+	lowered := strings.ToLower(password)
+	if p.cfg.DisallowUserInfo && containsUserInfo(lowered, user) {
+		violations = append(violations, ViolationContainsUser)
+	}
+	if _, ok := p.common[lowered]; ok {
+		violations = append(violations, ViolationCommonPassword)
+	}
+	breached, err := p.isBreached(password)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if breached {
+		violations = append(violations, ViolationBreachedPassword)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return apperror.Validation("password does not meet the password policy").
+		WithDetail("field", "password").
+		WithDetail("violations", violations)
+}
+
+// containsUserInfo reports whether the password contains the email, its local part or a part of the name
+func containsUserInfo(lowered string, user model.User) bool {
+	candidates := strings.Fields(strings.ToLower(user.Name))
+	if email := strings.ToLower(user.Email); email != "" {
+		candidates = append(candidates, email)
+		if at := strings.Index(email, "@"); at > 0 {
+			candidates = append(candidates, email[:at])
+		}
+	}
+	for _, c := range candidates {
+		if utf8.RuneCountInString(c) >= minUserInfoLength && strings.Contains(lowered, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBreached looks the password up in a local copy of a k-anonymity range dataset. Only the
+// file of the first five hex chars of the SHA-1 is read, its lines are SUFFIX:COUNT.
+func (p *PasswordPolicy) isBreached(password string) (bool, error) {
+	if p.cfg.BreachedPrefixDir == "" {
+		return false, nil
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(p.cfg.BreachedPrefixDir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
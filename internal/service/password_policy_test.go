@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// violations returns what a policy error reports, nil for a password that passed
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var appErr *apperror.Error
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	require.Equal(t, apperror.CodeValidationFailed, appErr.Code)
+	return appErr.Details["violations"].([]string)
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := config.PasswordPolicyConfig{
+		MinLength:        10,
+		MaxLength:        20,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUserInfo: true,
+	}
+	user := model.User{Name: "Al Lovelace", Email: "countess@example.com"}
+	tests := []struct {
+		name     string
+		cfg      config.PasswordPolicyConfig
+		password string
+		want     []string
+	}{
+		{name: "empty without a minimum", cfg: config.PasswordPolicyConfig{}, password: "", want: []string{ViolationTooShort}},
+		{name: "anything without rules", cfg: config.PasswordPolicyConfig{}, password: "a"},
+		{name: "strong", cfg: strict, password: "Tr0ub4dor&3x"},
+		{name: "too short", cfg: strict, password: "Tr0ub&3", want: []string{ViolationTooShort}},
+		{name: "too long", cfg: strict, password: "Tr0ub4dor&3xTr0ub4dor&3x", want: []string{ViolationTooLong}},
+		{name: "length counts runes", cfg: strict, password: "Tr0ub4dör&3ééééééééé"},
+		{name: "missing classes", cfg: strict, password: "abcdefghijk", want: []string{ViolationMissingUpper, ViolationMissingDigit, ViolationMissingSymbol}},
+		{name: "space is a symbol", cfg: strict, password: "Tr0ub4dor 3x"},
+		{name: "contains name", cfg: strict, password: "Lovelace&1234", want: []string{ViolationContainsUser}},
+		{name: "contains email local part", cfg: strict, password: "Countess#2024", want: []string{ViolationContainsUser}},
+		{name: "short name parts are ignored", cfg: strict, password: "Xal#2024abcD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPasswordPolicy(tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, violations(t, p.Validate(tt.password, user)))
+		})
+	}
+}
+
+func TestPasswordPolicyLists(t *testing.T) {
+	dir := t.TempDir()
+	common := filepath.Join(dir, "common.txt")
+	require.NoError(t, os.WriteFile(common, []byte("password1\n\nletmein\nqwerty\n"), 0o600))
+
+	breached := "Breached#Pass1"
+	sum := sha1.Sum([]byte(breached))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefixDir := filepath.Join(dir, "ranges")
+	require.NoError(t, os.Mkdir(prefixDir, 0o700))
+	ranges := "0000000000000000000000000000000000A:3\n" + strings.ToLower(hash[5:]) + ":42\n"
+	require.NoError(t, os.WriteFile(filepath.Join(prefixDir, hash[:5]+".txt"), []byte(ranges), 0o600))
+
+	p, err := NewPasswordPolicy(config.PasswordPolicyConfig{
+		CommonPasswordsFile:  common,
+		CommonPasswordsLimit: 2,
+		BreachedPrefixDir:    prefixDir,
+	})
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "common, any case", password: "LetMeIn", want: []string{ViolationCommonPassword}},
+		{name: "beyond the limit", password: "qwerty"},
+		{name: "breached", password: breached, want: []string{ViolationBreachedPassword}},
+		{name: "no range file", password: "Unlisted#Pass1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, violations(t, p.Validate(tt.password, model.User{})))
+		})
+	}
+
+	_, err = NewPasswordPolicy(config.PasswordPolicyConfig{CommonPasswordsFile: filepath.Join(dir, "missing.txt")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newPasswordHash matches the hash of password handed to UpdatePassword
+func newPasswordHash(s *UserService, password string) interface{} {
+	return mock.MatchedBy(func(hash string) bool {
+		return s.Hasher.Verify(password, hash) == nil
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		oldPassword string
+		newPassword string
+		wantCode    apperror.Code
+	}{
+		{name: "changed", oldPassword: "correct horse", newPassword: "battery staple"},
+		{name: "wrong old password", oldPassword: "battery staple", newPassword: "battery staple", wantCode: apperror.CodeValidationFailed},
+		{name: "no old password", newPassword: "battery staple", wantCode: apperror.CodeValidationFailed},
+		{name: "empty new password", oldPassword: "correct horse", wantCode: apperror.CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedNow(t, now)
+			s, m := newTestUserService(t)
+			ctx := context.Background()
+			m.users.On("FindByID", ctx, testUserID).Return(testUser(t, s, "correct horse"), nil)
+			if tt.wantCode == "" {
+				m.users.On("UpdatePassword", ctx, testUserID, newPasswordHash(s, tt.newPassword)).Return(nil)
+				// the session making the change ends too
+				m.redis.On("RevokeUserTokens", ctx, testUserID, now, time.Hour).Return(nil)
+			}
+
+			err := s.ChangePassword(ctx, testUserID, tt.oldPassword, tt.newPassword)
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+		})
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.users.On("FindByEmail", ctx, "ada@example.com").Return(testUser(t, s, "correct horse"), nil)
+	var stored string
+	m.redis.On("StorePasswordResetToken", ctx, mock.AnythingOfType("string"), testUserID, 30*time.Minute).Run(func(args mock.Arguments) {
+		stored = args.String(1)
+	}).Return(nil)
+	var sent mailer.PasswordResetData
+	m.mail.On("Send", ctx, "ada@example.com", mailer.TemplatePasswordReset, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(3).(mailer.PasswordResetData)
+	}).Return(nil)
+
+	require.NoError(t, s.RequestPasswordReset(ctx, "ada@example.com"))
+	link, err := url.Parse(sent.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", link.Host)
+	// only the hash of the mailed token is stored
+	assert.Equal(t, util.HashToken(link.Query().Get("token")), stored)
+	assert.Equal(t, 30, sent.ValidMinutes)
+}
+
+func TestRequestPasswordResetUnknownEmail(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.users.On("FindByEmail", ctx, "nobody@example.com").Return(nil, repository.ErrNotFound)
+
+	// nothing tells the caller that there is no such account
+	assert.NoError(t, s.RequestPasswordReset(ctx, "nobody@example.com"))
+}
+
+func TestResetPassword(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	tokenHash := util.HashToken("reset-token")
+	tests := []struct {
+		name        string
+		newPassword string
+		setup       func(s *UserService, m userServiceMocks, user *model.User)
+		wantErr     error
+		wantCode    apperror.Code
+	}{
+		{
+			name:        "reset",
+			newPassword: "battery staple",
+			setup: func(s *UserService, m userServiceMocks, user *model.User) {
+				m.redis.On("GetPasswordResetToken", mock.Anything, tokenHash).Return(testUserID, nil)
+				m.users.On("FindByID", mock.Anything, testUserID).Return(user, nil)
+				m.redis.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return(testUserID, nil)
+				m.users.On("UpdatePassword", mock.Anything, testUserID, newPasswordHash(s, "battery staple")).Return(nil)
+				m.redis.On("RevokeUserTokens", mock.Anything, testUserID, now, time.Hour).Return(nil)
+			},
+		},
+		{
+			name:        "used or expired token",
+			newPassword: "battery staple",
+			setup: func(s *UserService, m userServiceMocks, user *model.User) {
+				m.redis.On("GetPasswordResetToken", mock.Anything, tokenHash).Return("", repository.ErrNotFound)
+			},
+			wantErr: errInvalidResetToken,
+		},
+		{
+			name:        "used by a concurrent reset",
+			newPassword: "battery staple",
+			setup: func(s *UserService, m userServiceMocks, user *model.User) {
+				m.redis.On("GetPasswordResetToken", mock.Anything, tokenHash).Return(testUserID, nil)
+				m.users.On("FindByID", mock.Anything, testUserID).Return(user, nil)
+				m.redis.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return("", repository.ErrNotFound)
+			},
+			wantErr: errInvalidResetToken,
+		},
+		{
+			name:        "user gone",
+			newPassword: "battery staple",
+			setup: func(s *UserService, m userServiceMocks, user *model.User) {
+				m.redis.On("GetPasswordResetToken", mock.Anything, tokenHash).Return(testUserID, nil)
+				m.users.On("FindByID", mock.Anything, testUserID).Return(nil, repository.ErrNotFound)
+			},
+			wantErr: errInvalidResetToken,
+		},
+		{
+			// the token stays usable for a second try
+			name:        "password the policy refuses",
+			newPassword: "short",
+			setup: func(s *UserService, m userServiceMocks, user *model.User) {
+				m.redis.On("GetPasswordResetToken", mock.Anything, tokenHash).Return(testUserID, nil)
+				m.users.On("FindByID", mock.Anything, testUserID).Return(user, nil)
+			},
+			wantCode: apperror.CodeValidationFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedNow(t, now)
+			s, m := newTestUserService(t)
+			tt.setup(s, m, testUser(t, s, "correct horse"))
+
+			err := s.ResetPassword(context.Background(), "reset-token", tt.newPassword)
+			switch {
+			case tt.wantErr != nil:
+				assert.Equal(t, tt.wantErr, err)
+			case tt.wantCode != "":
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"time"
+)
+
+// UserPurger removes deleted users for good once their retention window has passed
+type UserPurger struct {
+	Repo      repository.IUserRepository
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// NewUserPurger creates a purger that runs every interval
+func NewUserPurger(repo repository.IUserRepository, retention time.Duration, interval time.Duration) *UserPurger {
+	return &UserPurger{
+		Repo:      repo,
+		Retention: retention,
+		Interval:  interval,
+	}
+}
+
+// Run purges right away and then every interval until ctx is done
+func (p *UserPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		p.Purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes the users deleted longer than the retention window ago
+func (p *UserPurger) Purge(ctx context.Context) {
+	// the purge is idempotent, instances running it at the same time don't get in each other's way
+	n, err := p.Repo.Purge(ctx, util.TimeNow().Add(-p.Retention))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if n > 0 {
+		log.Printf("purged %d deleted users", n)
+	}
+}
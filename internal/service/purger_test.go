@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	users := mocks.NewIUserRepository(t)
+	ctx := context.Background()
+	// only users deleted before the retention window began go
+	users.On("Purge", ctx, now.Add(-30*24*time.Hour)).Return(int64(2), nil)
+
+	NewUserPurger(users, 30*24*time.Hour, time.Hour).Purge(ctx)
+}
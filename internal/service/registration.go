@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"net/url"
+	"time"
+)
+
+const inviteTokenBytes = 32
+
+var (
+	errRegistrationDisabled = apperror.Forbidden("registration is disabled")
+	errInvalidInvitation    = apperror.Validation("invitation is invalid or expired").WithDetail("field", "inviteToken")
+)
+
+// Register creates a self-service account with the configured default role. Without an
+// invitation the account stays pending until its email is verified, an invitation already
+// proves the email so the account is active right away.
+func (s *UserService) Register(ctx context.Context, user model.User, inviteToken string) (*model.User, error) {
+	mode := s.Cfg.RegistrationConfig.Mode
+	if mode != config.RegistrationOpen && mode != config.RegistrationInviteOnly {
+		return nil, errRegistrationDisabled
+	}
+	if mode == config.RegistrationInviteOnly && inviteToken == "" {
+		return nil, apperror.Forbidden("registration requires an invitation")
+	}
+	email, err := parseEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = email
+	if err := s.Policy.Validate(user.Password, user); err != nil {
+		return nil, err
+	}
+
+	user.Role = s.Cfg.RegistrationConfig.DefaultRole
+	user.Status = model.UserStatusPending
+	user.EmailVerified = false
+
+	var inviteHash string
+	if inviteToken != "" {
+		inviteHash = util.HashToken(inviteToken)
+		inv, err := s.RedisRepo.GetInvitation(ctx, inviteHash)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errInvalidInvitation
+		}
+		if err != nil {
+			return nil, apperror.Internal(err)
+		}
+		if inv.Email != user.Email {
+			return nil, errInvalidInvitation
+		}
+		user.Status = model.UserStatusActive
+		user.EmailVerified = true
+		user.TenantID = inv.TenantID
+	}
+
+	created, err := s.createUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	event := userAuditEvent(model.AuditUserRegister, created)
+	event.ActorID = string(created.ID)
+	s.Audit.Record(ctx, event, nil, created)
+
+	if inviteHash != "" {
+		if err := s.RedisRepo.DeleteInvitation(ctx, inviteHash, user.Email); err != nil {
+			log.Println(err)
+		}
+		return created, nil
+	}
+	// the account exists at this point, a failed mail can be sent again with sendVerificationEmail
+	if err := s.sendVerification(ctx, created); err != nil {
+		log.Println(err)
+	}
+	return created, nil
+}
+
+// InviteUser mails an invitation to register to the email on behalf of invitedBy
+func (s *UserService) InviteUser(ctx context.Context, email string, invitedBy string) error {
+	if s.Cfg.RegistrationConfig.Mode != config.RegistrationOpen && s.Cfg.RegistrationConfig.Mode != config.RegistrationInviteOnly {
+		return errRegistrationDisabled
+	}
+	email, err := parseEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := s.checkEmailAvailable(ctx, email, ""); err != nil {
+		return err
+	}
+	inviter, err := s.Repo.FindByID(ctx, invitedBy)
+	if err != nil {
+		return userLookupError(err)
+	}
+
+	token, err := util.GenerateRandomToken(inviteTokenBytes)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	// the invited user joins the organization of whoever invited them
+	inv := model.Invitation{Email: email, InvitedBy: invitedBy, TenantID: inviter.TenantID}
+	ttl := s.Cfg.RegistrationConfig.InviteTTL * time.Second
+	if err := s.RedisRepo.StoreInvitation(ctx, util.HashToken(token), inv, ttl); err != nil {
+		return apperror.Internal(err)
+	}
+
+	link := s.Cfg.RegistrationConfig.InviteURL + "?" + url.Values{"token": {token}, "email": {email}}.Encode()
+	err = s.Mailer.Send(ctx, email, mailer.TemplateInvitation, mailer.InvitationData{
+		InvitedBy: inviter.Name,
+		URL:       link,
+		ValidDays: int(s.Cfg.RegistrationConfig.InviteTTL / 86400),
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	// there is no user yet, the invitation is about the email
+	s.Audit.Record(ctx, model.AuditEvent{Action: model.AuditUserInvite, TargetID: email, TenantID: inviter.TenantID}, nil, nil)
+	return nil
+}
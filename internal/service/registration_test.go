@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	inviteHash := util.HashToken("invite-token")
+	tests := []struct {
+		name        string
+		mode        string
+		user        model.User
+		inviteToken string
+		// the email the invitation was sent to
+		invitedEmail string
+		wantStatus   string
+		wantErr      error
+		wantCode     apperror.Code
+	}{
+		{
+			name:       "open",
+			mode:       config.RegistrationOpen,
+			user:       model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"},
+			wantStatus: model.UserStatusPending,
+		},
+		{
+			name:       "asking for a role",
+			mode:       config.RegistrationOpen,
+			user:       model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse", Role: "Admin"},
+			wantStatus: model.UserStatusPending,
+		},
+		{
+			name:         "invited",
+			mode:         config.RegistrationInviteOnly,
+			user:         model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"},
+			inviteToken:  "invite-token",
+			invitedEmail: "ada@example.com",
+			wantStatus:   model.UserStatusActive,
+		},
+		{
+			name:         "invitation for another email",
+			mode:         config.RegistrationInviteOnly,
+			user:         model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"},
+			inviteToken:  "invite-token",
+			invitedEmail: "grace@example.com",
+			wantErr:      errInvalidInvitation,
+		},
+		{
+			name:     "invite only without an invitation",
+			mode:     config.RegistrationInviteOnly,
+			user:     model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"},
+			wantCode: apperror.CodeForbidden,
+		},
+		{
+			name:    "disabled",
+			mode:    config.RegistrationDisabled,
+			user:    model.User{Name: "Ada", Email: "ada@example.com", Password: "correct horse"},
+			wantErr: errRegistrationDisabled,
+		},
+		{
+			name:     "no password",
+			mode:     config.RegistrationOpen,
+			user:     model.User{Name: "Ada", Email: "ada@example.com"},
+			wantCode: apperror.CodeValidationFailed,
+		},
+		{
+			name:     "invalid email",
+			mode:     config.RegistrationOpen,
+			user:     model.User{Name: "Ada", Email: "ada", Password: "correct horse"},
+			wantCode: apperror.CodeValidationFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			s.Cfg.RegistrationConfig = config.RegistrationConfig{Mode: tt.mode, DefaultRole: "User"}
+			s.Cfg.EmailVerifyConfig.TTL = 86400
+			ctx := context.Background()
+			if tt.invitedEmail != "" {
+				m.redis.On("GetInvitation", mock.Anything, inviteHash).Return(&model.Invitation{Email: tt.invitedEmail}, nil)
+			}
+			if tt.wantStatus != "" {
+				m.roles.On("FindByName", mock.Anything, "User").Return(&model.Role{Name: "User"}, nil)
+				m.users.On("FindByEmail", mock.Anything, tt.user.Email).Return(nil, repository.ErrNotFound)
+				m.users.On("Create", mock.Anything, mock.MatchedBy(func(u model.User) bool {
+					return u.Role == "User" && u.Status == tt.wantStatus && u.EmailVerified == (tt.wantStatus == model.UserStatusActive)
+				})).Return(func(_ context.Context, u model.User) *model.User {
+					u.ID = testUserID
+					return &u
+				}, nil)
+			}
+			switch tt.wantStatus {
+			case model.UserStatusActive:
+				m.redis.On("DeleteInvitation", mock.Anything, inviteHash, tt.user.Email).Return(nil)
+			case model.UserStatusPending:
+				m.redis.On("StoreEmailVerificationToken", mock.Anything, mock.AnythingOfType("string"), model.EmailVerification{UserID: testUserID, Email: tt.user.Email}, mock.Anything).Return(nil)
+				m.mail.On("Send", mock.Anything, tt.user.Email, mailer.TemplateEmailVerification, mock.Anything).Return(nil)
+			}
+
+			got, err := s.Register(ctx, tt.user, tt.inviteToken)
+			switch {
+			case tt.wantErr != nil:
+				assert.Equal(t, tt.wantErr, err)
+			case tt.wantCode != "":
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "User", got.Role)
+				assert.Equal(t, tt.wantStatus, got.Status)
+			}
+		})
+	}
+}
+
+func TestInviteUserWhenDisabled(t *testing.T) {
+	s, _ := newTestUserService(t)
+	s.Cfg.RegistrationConfig.Mode = config.RegistrationDisabled
+
+	assert.Equal(t, errRegistrationDisabled, s.InviteUser(context.Background(), "grace@example.com", testUserID))
+}
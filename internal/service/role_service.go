@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"strings"
+)
+
+type IRoleService interface {
+	GetRoles(ctx context.Context) ([]model.Role, error)
+	CreateRole(ctx context.Context, role model.Role) (*model.Role, error)
+	UpdateRole(ctx context.Context, name string, permissions []string) (*model.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+}
+
+var (
+	errRoleNotFound     = apperror.NotFound("role not found")
+	errRoleExists       = apperror.Conflict("role already exists").WithDetail("field", "name")
+	errDefaultRoleInUse = apperror.Conflict("role is the default role of new registrations")
+)
+
+// roleManagerPermissions are the permissions changing roles needs, some role must keep each of them
+var roleManagerPermissions = []string{PermRoleManage, PermOrgManage}
+
+// errLastRoleManager is built per call since the message names the permission
+func errLastRoleManager(perm string) error {
+	return apperror.Conflict("at least one role must keep the "+perm+" permission").WithDetail("permission", perm)
+}
+
+type RoleService struct {
+	Repo     repository.IRoleRepository
+	UserRepo repository.IUserRepository
+	Authz    *RoleAuthorizer
+	Audit    *AuditLogger
+	Cfg      *config.Config
+}
+
+// NewRoleService creates a new service instance for role management
+func NewRoleService(repo repository.IRoleRepository, userRepo repository.IUserRepository, authz *RoleAuthorizer, audit *AuditLogger, cfg *config.Config) *RoleService {
+	return &RoleService{
+		Repo:     repo,
+		UserRepo: userRepo,
+		Authz:    authz,
+		Audit:    audit,
+		Cfg:      cfg,
+	}
+}
+
+// SeedRoles stores the configured roles when the roles collection is still empty
+func (s *RoleService) SeedRoles(ctx context.Context, roles []config.RoleConfig) error {
+	seed := make([]model.Role, 0, len(roles))
+	for _, rc := range roles {
+		role, err := newRole(rc.Name, rc.Permissions)
+		if err != nil {
+			return err
+		}
+		seed = append(seed, role)
+	}
+	return s.Repo.Seed(ctx, seed)
+}
+
+// GetRoles returns every role
+func (s *RoleService) GetRoles(ctx context.Context) ([]model.Role, error) {
+	roles, err := s.Repo.FindAll(ctx)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return roles, nil
+}
+
+// CreateRole adds a role users can be assigned to
+func (s *RoleService) CreateRole(ctx context.Context, role model.Role) (*model.Role, error) {
+	if err := checkSharedRoleChange(ctx); err != nil {
+		return nil, err
+	}
+	role, err := newRole(role.Name, role.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.Repo.Create(ctx, role)
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, errRoleExists
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	s.Authz.Invalidate()
+	s.Audit.Record(ctx, model.AuditEvent{Action: model.AuditRoleCreate, TargetID: created.Name}, nil, created)
+	return created, nil
+}
+
+// UpdateRole replaces the permissions of a role. Roles can't be renamed since users refer to them by name.
+func (s *RoleService) UpdateRole(ctx context.Context, name string, permissions []string) (*model.Role, error) {
+	if err := checkSharedRoleChange(ctx); err != nil {
+		return nil, err
+	}
+	role, err := newRole(name, permissions)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.findRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOtherRoleManagers(ctx, name, role.Permissions); err != nil {
+		return nil, err
+	}
+	updated, err := s.Repo.UpdatePermissions(ctx, name, role.Permissions)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRoleNotFound
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	s.Authz.Invalidate()
+	s.Audit.Record(ctx, model.AuditEvent{Action: model.AuditRoleUpdate, TargetID: name}, current, updated)
+	return updated, nil
+}
+
+// DeleteRole removes a role nobody is assigned to
+func (s *RoleService) DeleteRole(ctx context.Context, name string) error {
+	if err := checkSharedRoleChange(ctx); err != nil {
+		return err
+	}
+	if name == s.Cfg.RegistrationConfig.DefaultRole {
+		return errDefaultRoleInUse
+	}
+	current, err := s.findRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	// roles are shared by all organizations, and deleted users keep theirs when restored
+	users, err := s.UserRepo.Count(repository.AllTenants(ctx), model.UserFilter{Role: name, IncludeDeleted: true})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if users > 0 {
+		return apperror.Conflict("role is still assigned to users").WithDetail("users", users)
+	}
+	if err := s.checkOtherRoleManagers(ctx, name, nil); err != nil {
+		return err
+	}
+	err = s.Repo.Delete(ctx, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errRoleNotFound
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	s.Authz.Invalidate()
+	s.Audit.Record(ctx, model.AuditEvent{Action: model.AuditRoleDelete, TargetID: name}, current, nil)
+	return nil
+}
+
+func (s *RoleService) findRole(ctx context.Context, name string) (*model.Role, error) {
+	role, err := s.Repo.FindByName(ctx, name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRoleNotFound
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return role, nil
+}
+
+// checkOtherRoleManagers makes sure roles can still be changed once the role name only keeps
+// the permissions kept: another role has to hold every permission changing roles needs that
+// name gives up
+func (s *RoleService) checkOtherRoleManagers(ctx context.Context, name string, kept []string) error {
+	var roles []model.Role
+	for _, perm := range roleManagerPermissions {
+		if util.IsMemberofStringSlice(kept, perm) {
+			continue
+		}
+		if roles == nil {
+			var err error
+			if roles, err = s.Repo.FindAll(ctx); err != nil {
+				return apperror.Internal(err)
+			}
+		}
+		if !otherRoleHolds(roles, name, perm) {
+			return errLastRoleManager(perm)
+		}
+	}
+	return nil
+}
+
+func otherRoleHolds(roles []model.Role, name string, perm string) bool {
+	for _, role := range roles {
+		if role.Name != name && util.IsMemberofStringSlice(role.Permissions, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRole validates the name and permissions of a role, duplicate permissions are dropped
+func newRole(name string, permissions []string) (model.Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return model.Role{}, apperror.Validation("role name must not be empty").WithDetail("field", "name")
+	}
+	role := model.Role{Name: name, Permissions: []string{}}
+	for _, perm := range permissions {
+		if !util.IsMemberofStringSlice(Permissions, perm) {
+			return model.Role{}, apperror.Validation("unknown permission "+perm).WithDetail("field", "permissions")
+		}
+		if !util.IsMemberofStringSlice(role.Permissions, perm) {
+			role.Permissions = append(role.Permissions, perm)
+		}
+	}
+	return role, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRoleKeepsRoleManagers(t *testing.T) {
+	superAdmin := model.Role{Name: "SuperAdmin", Permissions: []string{PermRoleManage, PermOrgManage}}
+	tests := []struct {
+		name        string
+		permissions []string
+		others      []model.Role
+		wantLast    string
+	}{
+		{name: "keeps both", permissions: []string{PermRoleManage, PermOrgManage, PermUserRead}},
+		{
+			name:        "another role manages all organizations",
+			permissions: []string{PermRoleManage},
+			others:      []model.Role{{Name: "Operator", Permissions: []string{PermOrgManage}}},
+		},
+		{name: "last role managing all organizations", permissions: []string{PermRoleManage}, wantLast: PermOrgManage},
+		{name: "last role managing roles", permissions: []string{PermOrgManage}, wantLast: PermRoleManage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles := mocks.NewIRoleRepository(t)
+			s := NewRoleService(roles, nil, NewRoleAuthorizer(roles, mocks.NewIGroupRepository(t), time.Minute), nil, nil)
+			ctx := asPrincipal("", PermRoleManage, PermOrgManage)
+			roles.On("FindByName", ctx, "SuperAdmin").Return(&superAdmin, nil)
+			roles.On("FindAll", ctx).Return(append([]model.Role{superAdmin}, tt.others...), nil).Maybe()
+			if tt.wantLast == "" {
+				roles.On("UpdatePermissions", ctx, "SuperAdmin", tt.permissions).Return(&model.Role{Name: "SuperAdmin", Permissions: tt.permissions}, nil)
+			}
+
+			_, err := s.UpdateRole(ctx, "SuperAdmin", tt.permissions)
+			if tt.wantLast == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var appErr *apperror.Error
+			require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+			assert.Equal(t, apperror.CodeConflict, appErr.Code)
+			assert.Equal(t, tt.wantLast, appErr.Details["permission"])
+			roles.AssertNotCalled(t, "UpdatePermissions", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
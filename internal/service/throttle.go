@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+	"log"
+	"strings"
+	"time"
+)
+
+// loginThrottleKey is one of the counters a login attempt is checked against
+type loginThrottleKey struct {
+	key          string
+	freeAttempts int64
+	lockout      bool
+}
+
+// loginThrottleKeys returns the counters of the email and, when known, the client ip
+func (s *UserService) loginThrottleKeys(email string, clientIP string) []loginThrottleKey {
+	cfg := s.Cfg.LoginThrottleConfig
+	keys := []loginThrottleKey{{key: loginEmailKey(email), freeAttempts: cfg.FreeAttempts, lockout: true}}
+	if clientIP != "" {
+		keys = append(keys, loginThrottleKey{key: "ip:" + clientIP, freeAttempts: cfg.IPFreeAttempts})
+	}
+	return keys
+}
+
+// checkLoginThrottle refuses an attempt on a locked account or one made before the backoff
+// of the email or the client ip ran out. The refusal looks like a wrong password so it
+// doesn't tell whether the account exists.
+func (s *UserService) checkLoginThrottle(ctx context.Context, email string, clientIP string) error {
+	locked, err := s.RedisRepo.IsAccountLocked(ctx, email)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if locked {
+		return errInvalidCredentials
+	}
+	for _, k := range s.loginThrottleKeys(email, clientIP) {
+		failures, err := s.RedisRepo.GetLoginFailures(ctx, k.key)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		delay := s.loginDelay(failures.Count, k.freeAttempts)
+		if delay > 0 && util.TimeNow().Before(failures.LastFailureAt.Add(delay)) {
+			return errInvalidCredentials
+		}
+	}
+	return nil
+}
+
+// loginDelay is the wait after the last failure, it doubles with every failure past the free ones
+func (s *UserService) loginDelay(failures int64, freeAttempts int64) time.Duration {
+	cfg := s.Cfg.LoginThrottleConfig
+	excess := failures - freeAttempts
+	if excess <= 0 || cfg.BaseDelay <= 0 {
+		return 0
+	}
+	maxDelay := cfg.MaxDelay * time.Second
+	delay := cfg.BaseDelay * time.Second
+	for i := int64(1); i < excess && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// recordLoginFailure counts the failure for the email and the client ip and locks the
+// account once the email reached the lockout threshold
+func (s *UserService) recordLoginFailure(ctx context.Context, email string, clientIP string) {
+	cfg := s.Cfg.LoginThrottleConfig
+	for _, k := range s.loginThrottleKeys(email, clientIP) {
+		failures, err := s.RedisRepo.RecordLoginFailure(ctx, k.key, cfg.Window*time.Second)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !k.lockout || cfg.LockoutThreshold <= 0 || failures.Count < cfg.LockoutThreshold {
+			continue
+		}
+		if err := s.RedisRepo.LockAccount(ctx, email, cfg.LockoutDuration*time.Second); err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := s.RedisRepo.ClearLoginFailures(ctx, k.key); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// UnlockUser lifts the lockout of a user and forgets the failed logins of their email
+func (s *UserService) UnlockUser(ctx context.Context, id string) error {
+	user, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, user); err != nil {
+		return err
+	}
+	email := normalizeLoginEmail(user.Email)
+	if err := s.RedisRepo.UnlockAccount(ctx, email); err != nil {
+		return apperror.Internal(err)
+	}
+	if err := s.RedisRepo.ClearLoginFailures(ctx, loginEmailKey(email)); err != nil {
+		return apperror.Internal(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserUnlock, user), nil, nil)
+	return nil
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginEmailKey(email string) string {
+	return "email:" + email
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testThrottle = config.LoginThrottleConfig{
+	Window:           900,
+	FreeAttempts:     3,
+	IPFreeAttempts:   10,
+	BaseDelay:        2,
+	MaxDelay:         60,
+	LockoutThreshold: 10,
+	LockoutDuration:  1800,
+}
+
+func TestLoginDelay(t *testing.T) {
+	s, _ := newTestUserService(t)
+	s.Cfg.LoginThrottleConfig = testThrottle
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 5, want: 4 * time.Second},
+		{failures: 8, want: 32 * time.Second},
+		{failures: 9, want: time.Minute},
+		{failures: 1000, want: time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, s.loginDelay(tt.failures, testThrottle.FreeAttempts), "failures %d", tt.failures)
+	}
+
+	s.Cfg.LoginThrottleConfig.BaseDelay = 0
+	assert.Zero(t, s.loginDelay(100, testThrottle.FreeAttempts), "no delay without a base delay")
+}
+
+func TestCheckLoginThrottle(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		locked   bool
+		email    model.LoginFailures
+		ip       model.LoginFailures
+		wantCode apperror.Code
+	}{
+		{name: "no failures"},
+		{name: "free attempts", email: model.LoginFailures{Count: 3, LastFailureAt: now}},
+		{name: "locked", locked: true, wantCode: apperror.CodeUnauthenticated},
+		{name: "email backoff running", email: model.LoginFailures{Count: 5, LastFailureAt: now.Add(-3 * time.Second)}, wantCode: apperror.CodeUnauthenticated},
+		{name: "email backoff over", email: model.LoginFailures{Count: 5, LastFailureAt: now.Add(-4 * time.Second)}},
+		{name: "ip backoff running", ip: model.LoginFailures{Count: 11, LastFailureAt: now.Add(-time.Second)}, wantCode: apperror.CodeUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedNow(t, now)
+			s, m := newTestUserService(t)
+			s.Cfg.LoginThrottleConfig = testThrottle
+			ctx := context.Background()
+			m.redis.On("IsAccountLocked", ctx, "ada@example.com").Return(tt.locked, nil)
+			m.redis.On("GetLoginFailures", ctx, "email:ada@example.com").Return(&tt.email, nil).Maybe()
+			m.redis.On("GetLoginFailures", ctx, "ip:192.0.2.1").Return(&tt.ip, nil).Maybe()
+
+			err := s.checkLoginThrottle(ctx, "ada@example.com", "192.0.2.1")
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+			// a throttled login must look like a wrong password
+			assert.Equal(t, errInvalidCredentials, err)
+		})
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     int64
+		ip        int64
+		clientIP  string
+		wantLock  bool
+		threshold int64
+	}{
+		{name: "below the threshold", email: 9, ip: 9, clientIP: "192.0.2.1", threshold: 10},
+		{name: "threshold reached", email: 10, ip: 1, clientIP: "192.0.2.1", threshold: 10, wantLock: true},
+		{name: "an ip alone never locks", email: 1, ip: 50, clientIP: "192.0.2.1", threshold: 10},
+		{name: "without an ip", email: 12, threshold: 10, wantLock: true},
+		{name: "lockout turned off", email: 50, threshold: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			s.Cfg.LoginThrottleConfig = testThrottle
+			s.Cfg.LoginThrottleConfig.LockoutThreshold = tt.threshold
+			ctx := context.Background()
+			window := 15 * time.Minute
+			m.redis.On("RecordLoginFailure", ctx, "email:ada@example.com", window).Return(&model.LoginFailures{Count: tt.email}, nil)
+			if tt.clientIP != "" {
+				m.redis.On("RecordLoginFailure", ctx, "ip:"+tt.clientIP, window).Return(&model.LoginFailures{Count: tt.ip}, nil)
+			}
+			if tt.wantLock {
+				m.redis.On("LockAccount", ctx, "ada@example.com", 30*time.Minute).Return(nil)
+				// the failures that led to the lockout don't slow down the login after it
+				m.redis.On("ClearLoginFailures", ctx, "email:ada@example.com").Return(nil)
+			}
+
+			s.recordLoginFailure(ctx, "ada@example.com", tt.clientIP)
+			if !tt.wantLock {
+				m.redis.AssertNotCalled(t, "LockAccount", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestLoginThrottled(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.redis.On("IsAccountLocked", ctx, "ada@example.com").Return(true, nil)
+
+	// the email is normalized before the throttle is checked, the account isn't even looked up
+	_, err := s.Login(ctx, model.User{Email: " Ada@Example.com", Password: "correct horse"}, "")
+	assert.Equal(t, errInvalidCredentials, err)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+	"log"
+	"time"
+)
+
+const (
+	refreshTokenBytes = 32
+	familyIDBytes     = 16
+	tokenIDBytes      = 16
+)
+
+var (
+	errInvalidRefreshToken = apperror.Unauthenticated("invalid refresh token")
+	errUnauthenticated     = apperror.Unauthenticated("you need to sign in")
+)
+
+// RefreshToken exchanges a refresh token for a new token pair. Every refresh token can be
+// used once; presenting one that was already rotated revokes its whole family.
+func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthToken, error) {
+	if refreshToken == "" {
+		return nil, errInvalidRefreshToken
+	}
+	tokenHash := util.HashToken(refreshToken)
+	stored, err := s.RedisRepo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	head, err := s.RedisRepo.GetRefreshFamilyHead(ctx, stored.FamilyID)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	if head == "" {
+		return nil, errInvalidRefreshToken
+	}
+	if head != tokenHash {
+		s.revokeRefreshFamily(ctx, stored.FamilyID)
+		return nil, errInvalidRefreshToken
+	}
+	// a family missing from the user's list survives a revocation of all the user's tokens
+	validAfter, err := s.RedisRepo.GetTokensValidAfter(ctx, stored.UserID)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	if stored.IssuedAt.Before(validAfter) {
+		s.revokeRefreshFamily(ctx, stored.FamilyID)
+		return nil, errInvalidRefreshToken
+	}
+
+	userData, err := s.Repo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		s.revokeRefreshFamily(ctx, stored.FamilyID)
+		return nil, errInvalidRefreshToken
+	}
+	// refresh tokens issued before sign-in methods were recorded all came from a password
+	method := stored.AuthMethod
+	if method == "" {
+		method = auth.MethodPassword
+	}
+	return s.issueTokens(ctx, userData, method, stored.FamilyID, tokenHash)
+}
+
+// issueTokens signs a new access token and a new refresh token for the user who signed in
+// with authMethod. An empty familyID starts a new family, otherwise prevHash is rotated out
+// of familyID.
+func (s *UserService) issueTokens(ctx context.Context, user *model.User, authMethod string, familyID string, prevHash string) (*model.AuthToken, error) {
+	jti, err := util.GenerateRandomToken(tokenIDBytes)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	claims := util.Claims{
+		ID:          string(user.ID),
+		Role:        user.Role,
+		TenantID:    user.TenantID,
+		Groups:      user.GroupIDs,
+		AuthMethods: auth.AMR(authMethod),
+	}
+	claims.RegisteredClaims.ID = jti
+	accessToken, err := util.GenerateToken(
+		claims,
+		s.Cfg.AuthTokenConfig.Duration,
+		s.Keys,
+	)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+
+	refreshToken, err := util.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	rt := model.RefreshToken{
+		TokenHash:  util.HashToken(refreshToken),
+		FamilyID:   familyID,
+		UserID:     string(user.ID),
+		AuthMethod: authMethod,
+		IssuedAt:   util.TimeNow(),
+	}
+	ttl := s.Cfg.AuthTokenConfig.RefreshDuration * time.Second
+
+	if familyID == "" {
+		rt.FamilyID, err = util.GenerateRandomToken(familyIDBytes)
+		if err != nil {
+			return nil, apperror.Internal(err)
+		}
+		if err := s.RedisRepo.StoreRefreshToken(ctx, rt, ttl); err != nil {
+			return nil, apperror.Internal(err)
+		}
+	} else {
+		rotated, err := s.RedisRepo.RotateRefreshToken(ctx, prevHash, rt, ttl)
+		if err != nil {
+			return nil, apperror.Internal(err)
+		}
+		if !rotated {
+			// another request won the race with the same token, treat it as reuse
+			s.revokeRefreshFamily(ctx, familyID)
+			return nil, errInvalidRefreshToken
+		}
+	}
+
+	return &model.AuthToken{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.Cfg.AuthTokenConfig.Duration),
+	}, nil
+}
+
+func (s *UserService) revokeRefreshFamily(ctx context.Context, familyID string) {
+	if err := s.RedisRepo.RevokeRefreshFamily(ctx, familyID); err != nil {
+		log.Println(err)
+	}
+}
+
+// Logout revokes the access token the caller used and, when given, the family of the
+// caller's refresh token
+func (s *UserService) Logout(ctx context.Context, refreshToken string) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return errUnauthenticated
+	}
+	if principal.TokenID != "" {
+		if ttl := time.Until(principal.ExpiresAt); ttl > 0 {
+			if err := s.RedisRepo.RevokeAccessToken(ctx, principal.TokenID, ttl); err != nil {
+				return apperror.Internal(err)
+			}
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := s.RedisRepo.GetRefreshToken(ctx, util.HashToken(refreshToken))
+	if err != nil || stored.UserID != principal.UserID {
+		return errInvalidRefreshToken
+	}
+	if err := s.RedisRepo.RevokeRefreshFamily(ctx, stored.FamilyID); err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// LogoutAllSessions revokes every access and refresh token issued to the user so far
+func (s *UserService) LogoutAllSessions(ctx context.Context, userID string) error {
+	return s.revokeUserTokens(ctx, userID)
+}
+
+func (s *UserService) revokeUserTokens(ctx context.Context, userID string) error {
+	// tokens issued before now are dead once they expire, so the marker can go after that
+	ttl := max(s.Cfg.AuthTokenConfig.Duration, s.Cfg.AuthTokenConfig.RefreshDuration) * time.Second
+	if err := s.RedisRepo.RevokeUserTokens(ctx, userID, util.TimeNow(), ttl); err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshToken(t *testing.T) {
+	const presented = "presented-refresh-token"
+	tokenHash := util.HashToken(presented)
+	tests := []struct {
+		name string
+		// what redis knows about the presented token
+		storedErr error
+		head      string
+		// when all the user's tokens were last revoked
+		validAfter time.Time
+		rotated    bool
+		// whether the family gets revoked
+		wantRevoke bool
+		wantErr    bool
+	}{
+		{name: "family head", head: tokenHash, rotated: true},
+		{name: "unknown token", storedErr: errors.New("redis: nil"), wantErr: true},
+		{name: "expired family", head: "", wantErr: true},
+		{name: "reused token", head: util.HashToken("its successor"), wantRevoke: true, wantErr: true},
+		{name: "lost the race with the same token", head: tokenHash, rotated: false, wantRevoke: true, wantErr: true},
+		{name: "issued before the user's tokens were revoked", head: tokenHash, validAfter: time.Date(2024, 11, 22, 9, 0, 0, 0, time.UTC), wantRevoke: true, wantErr: true},
+		{name: "issued earlier in the second of the revocation", head: tokenHash, validAfter: time.Date(2024, 11, 22, 8, 30, 0, 500000, time.UTC), wantRevoke: true, wantErr: true},
+		{name: "issued after the user's tokens were revoked", head: tokenHash, validAfter: time.Date(2024, 11, 22, 8, 0, 0, 0, time.UTC), rotated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+			fixedNow(t, now)
+			s, m := newTestUserService(t)
+			ctx := context.Background()
+			stored := &model.RefreshToken{TokenHash: tokenHash, FamilyID: "family", UserID: testUserID, IssuedAt: now.Add(-time.Hour)}
+			if tt.storedErr != nil {
+				m.redis.On("GetRefreshToken", ctx, tokenHash).Return(nil, tt.storedErr)
+			} else {
+				m.redis.On("GetRefreshToken", ctx, tokenHash).Return(stored, nil)
+				m.redis.On("GetRefreshFamilyHead", ctx, "family").Return(tt.head, nil)
+			}
+			if tt.head == tokenHash {
+				m.redis.On("GetTokensValidAfter", ctx, testUserID).Return(tt.validAfter, nil)
+			}
+			if tt.head == tokenHash && !stored.IssuedAt.Before(tt.validAfter) {
+				m.users.On("FindByID", ctx, testUserID).Return(testUser(t, s, "correct horse"), nil)
+				m.redis.On("RotateRefreshToken", ctx, tokenHash, mock.MatchedBy(func(next model.RefreshToken) bool {
+					return next.FamilyID == "family" && next.UserID == testUserID && next.IssuedAt.Equal(now)
+				}), time.Hour).Return(tt.rotated, nil)
+			}
+			if tt.wantRevoke {
+				m.redis.On("RevokeRefreshFamily", ctx, "family").Return(nil)
+			}
+
+			got, err := s.RefreshToken(ctx, presented)
+			if tt.wantErr {
+				assert.Equal(t, errInvalidRefreshToken, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, got.Token)
+			assert.NotEqual(t, presented, got.RefreshToken)
+		})
+	}
+}
+
+func TestLoginStartsRefreshFamily(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.users.On("FindByEmail", ctx, "ada@example.com").Return(testUser(t, s, "correct horse"), nil)
+	var stored model.RefreshToken
+	m.redis.On("StoreRefreshToken", ctx, mock.Anything, time.Hour).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(model.RefreshToken)
+	}).Return(nil)
+	allowLogin(m)
+
+	got, err := s.Login(ctx, model.User{Email: "ada@example.com", Password: "correct horse"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, util.HashToken(got.RefreshToken), stored.TokenHash)
+	assert.NotEmpty(t, stored.FamilyID)
+	assert.Equal(t, testUserID, stored.UserID)
+}
+
+func TestLogout(t *testing.T) {
+	now := time.Now()
+	refreshHash := util.HashToken("refresh")
+	tests := []struct {
+		name         string
+		expiresAt    time.Time
+		refreshToken string
+		owner        string
+		wantRevoke   bool
+		wantErr      error
+	}{
+		{name: "access token", expiresAt: now.Add(10 * time.Minute)},
+		{name: "expired access token", expiresAt: now.Add(-time.Minute)},
+		{name: "with the refresh token", expiresAt: now.Add(10 * time.Minute), refreshToken: "refresh", owner: testUserID, wantRevoke: true},
+		{name: "someone else's refresh token", expiresAt: now.Add(10 * time.Minute), refreshToken: "refresh", owner: "6740a6a9a5a4cf3a1c5d1e02", wantErr: errInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			ctx := auth.NewContext(context.Background(), &auth.Principal{UserID: testUserID, TokenID: "jti", ExpiresAt: tt.expiresAt})
+			if tt.expiresAt.After(now) {
+				m.redis.On("RevokeAccessToken", ctx, "jti", mock.MatchedBy(func(ttl time.Duration) bool {
+					return ttl > 9*time.Minute && ttl <= 10*time.Minute
+				})).Return(nil)
+			}
+			if tt.refreshToken != "" {
+				m.redis.On("GetRefreshToken", ctx, refreshHash).Return(&model.RefreshToken{FamilyID: "family", UserID: tt.owner}, nil)
+			}
+			if tt.wantRevoke {
+				m.redis.On("RevokeRefreshFamily", ctx, "family").Return(nil)
+			}
+
+			err := s.Logout(ctx, tt.refreshToken)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestLogoutAnonymous(t *testing.T) {
+	s, _ := newTestUserService(t)
+
+	assert.Equal(t, errUnauthenticated, s.Logout(context.Background(), ""))
+}
+
+func TestLogoutAllSessions(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.redis.On("RevokeUserTokens", ctx, testUserID, now, time.Hour).Return(nil)
+
+	assert.NoError(t, s.LogoutAllSessions(ctx, testUserID))
+}
+
+func TestRoleChangeRevokesTokens(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	current := testUser(t, s, "correct horse")
+	m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User"}, nil)
+	m.roles.On("FindByName", ctx, "Admin").Return(&model.Role{Name: "Admin"}, nil)
+	m.users.On("FindByID", ctx, testUserID).Return(current, nil)
+	m.users.On("FindByEmail", mock.Anything, "ada@example.com").Return(current, nil)
+	m.users.On("Update", ctx, testUserID, mock.Anything).Return(func(_ context.Context, _ string, u model.User) *model.User {
+		return &u
+	}, nil)
+	// the tokens still carry the old role
+	m.redis.On("RevokeUserTokens", ctx, testUserID, now, time.Hour).Return(nil)
+
+	_, err := s.UpdateUser(ctx, testUserID, model.User{Name: "Ada", Email: "ada@example.com", Role: "Admin", Password: "correct horse"}, nil)
+	assert.NoError(t, err)
+}
@@ -4,53 +4,168 @@ import (
 	"context"
 	"errors"
 	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/auth"
+	"go-graphql-user-svc/internal/mailer"
 	"go-graphql-user-svc/internal/model"
 	"go-graphql-user-svc/internal/repository"
 	"go-graphql-user-svc/util"
+	"log"
+	"net/mail"
+	"strings"
+	"sync"
 )
 
 type IUserService interface {
-	Login(ctx context.Context, user model.User) (string, error)
+	Login(ctx context.Context, user model.User, clientIP string) (*model.AuthToken, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.AuthToken, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAllSessions(ctx context.Context, userID string) error
 	GetAllUser(ctx context.Context) *[]model.User
+	GetUsersConnection(ctx context.Context, args model.UserConnectionArgs) (*model.UserConnection, error)
 	CreateUser(ctx context.Context, user model.User) (*model.User, error)
 	GetUserByID(ctx context.Context, id string) (*model.User, error)
-	UpdateUser(ctx context.Context, id string, user model.User) (*model.User, error)
+	UpdateUser(ctx context.Context, id string, user model.User, expectedVersion *int64) (*model.User, error)
+	UpdateProfile(ctx context.Context, id string, profile model.ProfileUpdate, expectedVersion *int64) (*model.User, error)
+	ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	Register(ctx context.Context, user model.User, inviteToken string) (*model.User, error)
+	InviteUser(ctx context.Context, email string, invitedBy string) error
+	SendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	VerifyMFA(ctx context.Context, challenge string, code string) (*model.AuthToken, error)
+	EnrollMFAWithChallenge(ctx context.Context, challenge string) (*model.MFAEnrollment, error)
+	EnrollMFA(ctx context.Context, userID string) (*model.MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, userID string, code string) ([]string, error)
+	DisableMFA(ctx context.Context, userID string, code string) error
+	ResetMFA(ctx context.Context, userID string) error
+	UnlockUser(ctx context.Context, id string) error
 	DeleteUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) (*model.User, error)
+	AddUserToGroup(ctx context.Context, userID string, groupID string) (*model.User, error)
+	RemoveUserFromGroup(ctx context.Context, userID string, groupID string) (*model.User, error)
+}
+
+var (
+	errInvalidCredentials = apperror.Unauthenticated("invalid email or password")
+	errInvalidRole        = apperror.Validation("user role is invalid").WithDetail("field", "role")
+	errEmailTaken         = apperror.Conflict("email is already registered").WithDetail("field", "email")
+	errEmailNotVerified   = apperror.Unauthenticated("email address is not verified").WithDetail("reason", "EMAIL_NOT_VERIFIED")
+	errVersionConflict    = apperror.Conflict("user was changed in the meantime, reload it and try again").WithDetail("field", "expectedVersion")
+)
+
+// errStrongerUser is built per call since the detail names the permission
+func errStrongerUser(perm string) error {
+	return apperror.Forbidden("you cannot manage users holding permissions you don't hold").WithDetail("permission", perm)
 }
 
 type UserService struct {
-	Repo repository.IUserRepository
-	Cfg  *config.Config
+	Repo      repository.IUserRepository
+	RoleRepo  repository.IRoleRepository
+	OrgRepo   repository.IOrganizationRepository
+	GroupRepo repository.IGroupRepository
+	RedisRepo repository.RedisRepository
+	Keys      *util.KeySet
+	Hasher    util.PasswordHasher
+	Secrets   *util.SecretBox
+	Mailer    mailer.Mailer
+	Policy    IPasswordPolicy
+	Audit     *AuditLogger
+	Cfg       *config.Config
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 // NewUserService creates a new service instance for user-related operations
-func NewUserService(repo repository.IUserRepository, cfg *config.Config) *UserService {
+func NewUserService(repo repository.IUserRepository, roleRepo repository.IRoleRepository, orgRepo repository.IOrganizationRepository, groupRepo repository.IGroupRepository, redisRepo repository.RedisRepository, keys *util.KeySet, hasher util.PasswordHasher, secrets *util.SecretBox, mailer mailer.Mailer, policy IPasswordPolicy, audit *AuditLogger, cfg *config.Config) *UserService {
 	return &UserService{
-		Repo: repo,
-		Cfg:  cfg,
+		Repo:      repo,
+		RoleRepo:  roleRepo,
+		OrgRepo:   orgRepo,
+		GroupRepo: groupRepo,
+		RedisRepo: redisRepo,
+		Keys:      keys,
+		Hasher:    hasher,
+		Secrets:   secrets,
+		Mailer:    mailer,
+		Policy:    policy,
+		Audit:     audit,
+		Cfg:       cfg,
 	}
 }
 
-func (s *UserService) Login(ctx context.Context, user model.User) (string, error) {
+// Login checks the user's credentials and starts a new refresh token family. Failed
+// attempts are throttled per email and per client ip. Users with MFA get a challenge
+// to answer with VerifyMFA instead of the tokens.
+func (s *UserService) Login(ctx context.Context, user model.User, clientIP string) (*model.AuthToken, error) {
+	email := normalizeLoginEmail(user.Email)
+	if err := s.checkLoginThrottle(ctx, email, clientIP); err != nil {
+		return nil, err
+	}
 	userData, err := s.Repo.FindByEmail(ctx, user.Email)
-	if err != nil {
-		return "", errors.New("invalid email or password")
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, apperror.Internal(err)
 	}
-	if userData.ID == "" || user.Password == "" {
-		return "", errors.New("invalid email or password")
+	found := err == nil && userData.ID != ""
+	// unknown emails are checked against a dummy hash, so they take as long as a wrong password
+	hash := s.dummyPasswordHash()
+	if found {
+		hash = userData.Password
 	}
-	if util.ValidatePassword(user.Password, userData.Password) != nil {
-		return "", errors.New("invalid email or password")
+	verifyErr := s.Hasher.Verify(user.Password, hash)
+	if !found || user.Password == "" || verifyErr != nil {
+		s.recordLoginFailure(ctx, email, clientIP)
+		event := model.AuditEvent{Action: model.AuditLoginFailed}
+		if found {
+			event.TargetID, event.TenantID = string(userData.ID), userData.TenantID
+		}
+		s.Audit.Record(ctx, event, nil, nil)
+		return nil, errInvalidCredentials
 	}
-	token, err := util.GenerateToken(
-		util.Claims{ID: string(userData.ID), Role: userData.Role},
-		s.Cfg.AuthTokenConfig.Duration,
-		s.Cfg.AuthTokenConfig.SecretKey,
-	)
+	if s.Cfg.EmailVerifyConfig.Required && (!userData.EmailVerified || userData.IsPending()) {
+		return nil, errEmailNotVerified
+	}
+	if s.Hasher.NeedsRehash(userData.Password) {
+		s.rehashPassword(ctx, userData, user.Password)
+	}
+	// the failures are only forgotten once the second factor was passed as well
+	if s.mfaRequired(userData) {
+		return s.startMFAChallenge(ctx, userData)
+	}
+	if err := s.RedisRepo.ClearLoginFailures(ctx, loginEmailKey(email)); err != nil {
+		log.Println(err)
+	}
+	s.recordLogin(ctx, userData)
+	return s.issueTokens(ctx, userData, auth.MethodPassword, "", "")
+}
+
+// dummyPasswordHash returns a hash of the configured algorithm that no password matches
+func (s *UserService) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		token, err := util.GenerateRandomToken(32)
+		if err == nil {
+			s.dummyHash, err = s.Hasher.Hash(token)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	return s.dummyHash
+}
+
+// rehashPassword upgrades a legacy or weaker hash while the plain password is at hand.
+// Failing to do so doesn't fail the login, the next one tries again.
+func (s *UserService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hashedPassword, err := s.Hasher.Hash(password)
 	if err != nil {
-		return "", errors.New("internal error")
+		log.Println(err)
+		return
+	}
+	if err := s.Repo.RehashPassword(ctx, string(user.ID), user.Password, hashedPassword); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println(err)
 	}
-	return token, nil
 }
 
 // GetAllUser calls the repository to get all user
@@ -58,32 +173,327 @@ func (s *UserService) GetAllUser(ctx context.Context) *[]model.User {
 	return s.Repo.Getall(ctx)
 }
 
-// CreateUser calls the repository to create a new user
+// CreateUser calls the repository to create a new user. Accounts created by an admin
+// don't need to verify their email.
 func (s *UserService) CreateUser(ctx context.Context, user model.User) (*model.User, error) {
-	if !util.IsMemberofStringSlice(s.Cfg.Roles, user.Role) {
-		return nil, errors.New("user role is invalid")
+	email, err := parseEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = email
+	if err := s.Policy.Validate(user.Password, user); err != nil {
+		return nil, err
+	}
+	if err := s.checkTenant(ctx, &user); err != nil {
+		return nil, err
+	}
+	// the new user holds the permissions of the role
+	if err := checkHeldGrants(ctx, s.RoleRepo, model.Group{Roles: []string{user.Role}}); err != nil {
+		return nil, err
+	}
+	user.Status = model.UserStatusActive
+	user.EmailVerified = true
+	created, err := s.createUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserCreate, created), nil, created)
+	return created, nil
+}
+
+func (s *UserService) createUser(ctx context.Context, user model.User) (*model.User, error) {
+	if err := s.checkRole(ctx, user.Role); err != nil {
+		return nil, err
+	}
+	if err := s.checkEmailAvailable(ctx, user.Email, ""); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.Hasher.Hash(user.Password)
+	if err != nil {
+		return nil, apperror.Internal(err)
 	}
-	hashedPassword, _ := util.HashPassword(user.Password)
 	user.Password = hashedPassword
-	return s.Repo.Create(ctx, user)
+	created, err := s.Repo.Create(ctx, user)
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, errEmailTaken
+	}
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	return created, nil
 }
 
 // GetUserByID calls the repository to get a user by its ID
 func (s *UserService) GetUserByID(ctx context.Context, id string) (*model.User, error) {
-	return s.Repo.FindByID(ctx, id)
+	user, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	return user, nil
 }
 
-// UpdateUser calls the repository to update a user's data
-func (s *UserService) UpdateUser(ctx context.Context, id string, user model.User) (*model.User, error) {
-	if !util.IsMemberofStringSlice(s.Cfg.Roles, user.Role) {
-		return nil, errors.New("user role is invalid")
+// UpdateUser calls the repository to update a user's data. A set expectedVersion has to match
+// the stored version, an update that changes nothing succeeds without a write.
+func (s *UserService) UpdateUser(ctx context.Context, id string, user model.User, expectedVersion *int64) (*model.User, error) {
+	email, err := parseEmail(user.Email)
+	if err != nil {
+		return nil, err
 	}
-	hashedPassword, _ := util.HashPassword(user.Password)
-	user.Password = hashedPassword
-	return s.Repo.Update(ctx, id, user)
+	user.Email = email
+	if err := s.checkRole(ctx, user.Role); err != nil {
+		return nil, err
+	}
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if expectedVersion != nil && *expectedVersion != current.Version {
+		return nil, errVersionConflict
+	}
+	if err := s.checkTarget(ctx, current); err != nil {
+		return nil, err
+	}
+	if user.Role != current.Role {
+		if err := checkHeldGrants(ctx, s.RoleRepo, model.Group{Roles: []string{user.Role}}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkEmailAvailable(ctx, user.Email, id); err != nil {
+		return nil, err
+	}
+	// an admin vouches for an email they set, otherwise the verification state stays
+	user.EmailVerified = current.EmailVerified || user.Email != current.Email
+	user.Status = current.Status
+	user.TenantID = current.TenantID
+	// an empty or re-sent password keeps the current hash, a new salt would make it look changed
+	if user.Password == "" || s.Hasher.Verify(user.Password, current.Password) == nil {
+		user.Password = current.Password
+	} else {
+		if err := s.Policy.Validate(user.Password, user); err != nil {
+			return nil, err
+		}
+		hashedPassword, err := s.Hasher.Hash(user.Password)
+		if err != nil {
+			return nil, apperror.Internal(err)
+		}
+		user.Password = hashedPassword
+	}
+	if user.Name == current.Name && user.Email == current.Email && user.Role == current.Role && user.Password == current.Password {
+		return current, nil
+	}
+	// without an expected version the update still must not clobber a change made since the read
+	user.Version = current.Version
+	updated, err := s.Repo.Update(ctx, id, user)
+	if err != nil {
+		return nil, updateError(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserUpdate, updated), current, updated)
+	// tokens carry the role, so a role change must not outlive them
+	if current.Role != updated.Role {
+		if err := s.revokeUserTokens(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// UpdateProfile changes the name and email of a user on their own behalf, role and password are kept
+func (s *UserService) UpdateProfile(ctx context.Context, id string, profile model.ProfileUpdate, expectedVersion *int64) (*model.User, error) {
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if expectedVersion != nil && *expectedVersion != current.Version {
+		return nil, errVersionConflict
+	}
+	user := *current
+	if profile.Name != nil {
+		name := strings.TrimSpace(*profile.Name)
+		if name == "" {
+			return nil, apperror.Validation("name must not be empty").WithDetail("field", "name")
+		}
+		user.Name = name
+	}
+	if profile.Email != nil {
+		email, err := parseEmail(*profile.Email)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkEmailAvailable(ctx, email, id); err != nil {
+			return nil, err
+		}
+		user.Email = email
+	}
+	if user.Name == current.Name && user.Email == current.Email {
+		return current, nil
+	}
+	emailChanged := user.Email != current.Email
+	if emailChanged {
+		user.EmailVerified = false
+	}
+	updated, err := s.Repo.Update(ctx, id, user)
+	if err != nil {
+		return nil, updateError(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserUpdateProfile, updated), current, updated)
+	if emailChanged {
+		if err := s.sendVerification(ctx, updated); err != nil {
+			log.Println(err)
+		}
+	}
+	return updated, nil
 }
 
-// DeleteUser calls the repository to delete a user by its ID
+// DeleteUser hides a user until it is restored or purged and revokes the user's tokens
 func (s *UserService) DeleteUser(ctx context.Context, id string) error {
-	return s.Repo.Delete(ctx, id)
+	current, err := s.Repo.FindByID(ctx, id)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, current); err != nil {
+		return err
+	}
+	if err := s.Repo.Delete(ctx, id); err != nil {
+		return userLookupError(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserDelete, current), current, nil)
+	return s.revokeUserTokens(ctx, id)
+}
+
+// RestoreUser brings back a deleted user within the retention window
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*model.User, error) {
+	deleted, err := s.Repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if err := s.checkTarget(ctx, deleted); err != nil {
+		return nil, err
+	}
+	restored, err := s.Repo.Restore(ctx, id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	s.Audit.Record(ctx, userAuditEvent(model.AuditUserRestore, restored), nil, nil)
+	return restored, nil
+}
+
+// parseEmail validates an email and returns the bare address, without a display name or
+// angle brackets
+func parseEmail(raw string) (string, error) {
+	addr, err := mail.ParseAddress(raw)
+	if err != nil {
+		return "", apperror.Validation("email is invalid").WithDetail("field", "email")
+	}
+	return addr.Address, nil
+}
+
+// checkEmailAvailable fails with a conflict when another user than exceptID owns the email.
+// Emails are unique across all organizations since signing in only asks for the email.
+func (s *UserService) checkEmailAvailable(ctx context.Context, email string, exceptID string) error {
+	existing, err := s.Repo.FindByEmail(repository.AllTenants(ctx), email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if string(existing.ID) != exceptID {
+		return errEmailTaken
+	}
+	return nil
+}
+
+// checkRole makes sure the role exists in the roles collection and the caller may assign it
+func (s *UserService) checkRole(ctx context.Context, role string) error {
+	found, err := s.RoleRepo.FindByName(ctx, role)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidRole
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return checkTenantGrant(ctx, found.Permissions)
+}
+
+// checkTarget keeps the caller away from users holding a permission the caller lacks, through
+// their role or their groups, so nobody can take over an account stronger than their own
+func (s *UserService) checkTarget(ctx context.Context, target *model.User) error {
+	grants := model.Group{Roles: []string{target.Role}}
+	if len(target.GroupIDs) > 0 {
+		groups, err := s.GroupRepo.FindByIDs(ctx, target.GroupIDs)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		for _, group := range groups {
+			grants.Roles = append(grants.Roles, group.Roles...)
+			grants.Permissions = append(grants.Permissions, group.Permissions...)
+		}
+	}
+	permissions, err := grantedPermissions(ctx, s.RoleRepo, grants)
+	if err != nil {
+		return err
+	}
+	if perm := unheldBy(ctx, permissions); perm != "" {
+		return errStrongerUser(perm)
+	}
+	return nil
+}
+
+// checkTenant puts a new user into the organization of a caller scoped to one, only
+// callers who manage all organizations may pick another
+func (s *UserService) checkTenant(ctx context.Context, user *model.User) error {
+	if tenantID, ok := repository.TenantFromContext(ctx); ok {
+		if user.TenantID != "" && user.TenantID != tenantID {
+			return errForeignOrganization
+		}
+		user.TenantID = tenantID
+		return nil
+	}
+	if user.TenantID == "" {
+		return nil
+	}
+	_, err := s.OrgRepo.FindByID(ctx, user.TenantID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.Validation("organization does not exist").WithDetail("field", "organizationId")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// recordLogin audits a sign-in that hands out tokens
+func (s *UserService) recordLogin(ctx context.Context, user *model.User) {
+	s.recordSelfAction(ctx, model.AuditLogin, user)
+}
+
+// recordSelfAction records what the user did to their own account, they may not be signed in yet
+func (s *UserService) recordSelfAction(ctx context.Context, action string, user *model.User) {
+	event := userAuditEvent(action, user)
+	event.ActorID = string(user.ID)
+	s.Audit.Record(ctx, event, nil, nil)
+}
+
+// userAuditEvent is an event about the user in the user's organization
+func userAuditEvent(action string, user *model.User) model.AuditEvent {
+	return model.AuditEvent{Action: action, TargetID: string(user.ID), TenantID: user.TenantID}
+}
+
+// userLookupError turns a failed repository lookup into a client facing error
+func userLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound("user not found")
+	}
+	return apperror.Internal(err)
+}
+
+// updateError turns a failed user update into a client facing error
+func updateError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		return errVersionConflict
+	case errors.Is(err, repository.ErrDuplicateKey):
+		return errEmailTaken
+	}
+	return userLookupError(err)
 }
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"go-graphql-user-svc/config"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func version(v int64) *int64 {
+	return &v
+}
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name            string
+		update          model.User
+		expectedVersion *int64
+		updateErr       error
+		wantUpdate      bool
+		wantCode        apperror.Code
+	}{
+		{
+			name:            "stale version",
+			update:          model.User{Name: "Ada L", Email: "ada@example.com", Role: "User"},
+			expectedVersion: version(2),
+			wantCode:        apperror.CodeConflict,
+		},
+		{
+			name:   "nothing changed",
+			update: model.User{Name: "Ada", Email: "ada@example.com", Role: "User"},
+		},
+		{
+			name:   "email with a display name",
+			update: model.User{Name: "Ada", Email: "Ada <ada@example.com>", Role: "User"},
+		},
+		{
+			name:            "same password sent again",
+			update:          model.User{Name: "Ada", Email: "ada@example.com", Role: "User", Password: "correct horse"},
+			expectedVersion: version(3),
+		},
+		{
+			name:            "new name",
+			update:          model.User{Name: "Ada L", Email: "ada@example.com", Role: "User"},
+			expectedVersion: version(3),
+			wantUpdate:      true,
+		},
+		{
+			name:       "new password",
+			update:     model.User{Name: "Ada", Email: "ada@example.com", Role: "User", Password: "battery staple"},
+			wantUpdate: true,
+		},
+		{
+			name:       "changed since the read",
+			update:     model.User{Name: "Ada L", Email: "ada@example.com", Role: "User"},
+			updateErr:  repository.ErrVersionConflict,
+			wantUpdate: true,
+			wantCode:   apperror.CodeConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			current := testUser(t, s, "correct horse")
+			ctx := context.Background()
+
+			m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User"}, nil)
+			m.users.On("FindByID", ctx, testUserID).Return(current, nil)
+			if tt.expectedVersion == nil || *tt.expectedVersion == current.Version {
+				m.users.On("FindByEmail", mock.Anything, "ada@example.com").Return(current, nil)
+			}
+			if tt.wantUpdate {
+				m.users.On("Update", ctx, testUserID, mock.MatchedBy(func(u model.User) bool {
+					return u.Version == current.Version
+				})).Return(func(_ context.Context, _ string, u model.User) *model.User {
+					return &u
+				}, tt.updateErr)
+			}
+
+			got, err := s.UpdateUser(ctx, testUserID, tt.update, tt.expectedVersion)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			if !tt.wantUpdate {
+				assert.Same(t, current, got)
+				return
+			}
+			assert.Equal(t, tt.update.Name, got.Name)
+			if tt.update.Password != "" {
+				assert.NoError(t, s.Hasher.Verify(tt.update.Password, got.Password))
+			}
+		})
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	roles := map[string]*model.Role{
+		"User": {Name: "User", Permissions: []string{PermUserRead}},
+		"Root": {Name: "Root", Permissions: []string{PermOrgManage}},
+	}
+	admin := asPrincipal("org1", PermUserRead, PermUserWrite)
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		role       string
+		groups     []model.Group
+		wantUnheld string
+	}{
+		{name: "weaker user", ctx: admin, role: "User"},
+		{name: "stronger role", ctx: admin, role: "Root", wantUnheld: PermOrgManage},
+		{name: "permission of a group", ctx: admin, role: "User", groups: []model.Group{{ID: testGroupID, Permissions: []string{PermRoleManage}}}, wantUnheld: PermRoleManage},
+		{name: "role of a group", ctx: admin, role: "User", groups: []model.Group{{ID: testGroupID, Roles: []string{"Root"}}}, wantUnheld: PermOrgManage},
+		{name: "as strong as the caller", ctx: asPrincipal("", PermUserWrite, PermOrgManage), role: "Root"},
+		{name: "deleted role", ctx: admin, role: "Nobody"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			target := testUser(t, s, "correct horse")
+			target.Role = tt.role
+			m.roles.On("FindByName", tt.ctx, mock.Anything).Return(func(_ context.Context, name string) (*model.Role, error) {
+				if role, ok := roles[name]; ok {
+					return role, nil
+				}
+				return nil, repository.ErrNotFound
+			})
+			for _, group := range tt.groups {
+				target.GroupIDs = append(target.GroupIDs, string(group.ID))
+			}
+			if len(tt.groups) > 0 {
+				m.groups.On("FindByIDs", tt.ctx, target.GroupIDs).Return(tt.groups, nil)
+			}
+
+			err := s.checkTarget(tt.ctx, target)
+			if tt.wantUnheld == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantUnheld, unheldPermission(t, err))
+		})
+	}
+}
+
+func TestCreateAndUpdateUserWithInvalidEmail(t *testing.T) {
+	s, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := s.CreateUser(ctx, model.User{Name: "Ada", Email: "ada@", Password: "correct horse", Role: "User"})
+	assert.Equal(t, apperror.CodeValidationFailed, apperror.CodeOf(err))
+	_, err = s.UpdateUser(ctx, testUserID, model.User{Name: "Ada", Email: "ada@", Role: "User"}, nil)
+	assert.Equal(t, apperror.CodeValidationFailed, apperror.CodeOf(err))
+}
+
+func TestUpdateUserToStrongerRole(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := asPrincipal("org1", PermUserRead, PermUserWrite)
+	m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User", Permissions: []string{PermUserRead}}, nil)
+	m.roles.On("FindByName", ctx, "Admin").Return(&model.Role{Name: "Admin", Permissions: []string{PermUserWrite, PermRoleManage}}, nil)
+	m.users.On("FindByID", ctx, testUserID).Return(testUser(t, s, "correct horse"), nil)
+
+	_, err := s.UpdateUser(ctx, testUserID, model.User{Name: "Ada", Email: "ada@example.com", Role: "Admin"}, nil)
+	assert.Equal(t, PermRoleManage, unheldPermission(t, err))
+}
+
+func TestUpdateProfile(t *testing.T) {
+	name := func(n string) *string { return &n }
+	tests := []struct {
+		name            string
+		profile         model.ProfileUpdate
+		expectedVersion *int64
+		wantUpdate      bool
+		wantCode        apperror.Code
+	}{
+		{
+			name:            "stale version",
+			profile:         model.ProfileUpdate{Name: name("Ada L")},
+			expectedVersion: version(4),
+			wantCode:        apperror.CodeConflict,
+		},
+		{
+			name:            "same name",
+			profile:         model.ProfileUpdate{Name: name(" Ada ")},
+			expectedVersion: version(3),
+		},
+		{
+			name:            "new name",
+			profile:         model.ProfileUpdate{Name: name("Ada L")},
+			expectedVersion: version(3),
+			wantUpdate:      true,
+		},
+		{
+			name:       "new name without a version",
+			profile:    model.ProfileUpdate{Name: name("Ada L")},
+			wantUpdate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			current := testUser(t, s, "correct horse")
+			ctx := context.Background()
+
+			m.users.On("FindByID", ctx, testUserID).Return(current, nil)
+			if tt.wantUpdate {
+				m.users.On("Update", ctx, testUserID, mock.Anything).Return(func(_ context.Context, _ string, u model.User) *model.User {
+					return &u
+				}, nil)
+			}
+
+			got, err := s.UpdateProfile(ctx, testUserID, tt.profile, tt.expectedVersion)
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			if !tt.wantUpdate {
+				assert.Same(t, current, got)
+				return
+			}
+			assert.Equal(t, *tt.profile.Name, got.Name)
+		})
+	}
+}
+
+func TestLoginRehash(t *testing.T) {
+	legacy := util.NewArgon2idHasher(config.Argon2Config{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	weaker := util.NewBcryptHasher(config.BcryptConfig{Cost: 4})
+	tests := []struct {
+		name       string
+		hasher     util.PasswordHasher
+		password   string
+		wantRehash bool
+		wantCode   apperror.Code
+	}{
+		{name: "current hash", password: "correct horse"},
+		{name: "other algorithm", hasher: legacy, password: "correct horse", wantRehash: true},
+		{name: "lower cost", hasher: weaker, password: "correct horse", wantRehash: true},
+		{name: "wrong password", hasher: legacy, password: "battery staple", wantCode: apperror.CodeUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			// the service prefers bcrypt with a cost above the weaker one
+			hasher, err := util.NewPasswordHasher(config.PasswordHashConfig{Algorithm: "bcrypt", Bcrypt: config.BcryptConfig{Cost: 5}})
+			require.NoError(t, err)
+			s.Hasher = hasher
+			user := testUser(t, s, "correct horse")
+			if tt.hasher != nil {
+				hash, err := tt.hasher.Hash("correct horse")
+				require.NoError(t, err)
+				user.Password = hash
+			}
+			stored := user.Password
+			ctx := context.Background()
+			allowLogin(m)
+			m.users.On("FindByEmail", ctx, user.Email).Return(user, nil)
+			if tt.wantRehash {
+				m.users.On("RehashPassword", ctx, testUserID, stored, mock.MatchedBy(func(hash string) bool {
+					return !s.Hasher.NeedsRehash(hash) && s.Hasher.Verify("correct horse", hash) == nil
+				})).Return(nil)
+			}
+
+			token, err := s.Login(ctx, model.User{Email: user.Email, Password: tt.password}, "")
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, apperror.CodeOf(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, token.Token)
+		})
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	allowLogin(m)
+	m.users.On("FindByEmail", ctx, "nobody@example.com").Return(nil, repository.ErrNotFound)
+
+	_, err := s.Login(ctx, model.User{Email: "nobody@example.com", Password: "correct horse"}, "")
+	assert.Equal(t, apperror.CodeUnauthenticated, apperror.CodeOf(err))
+	// the password was checked against the dummy hash like against a real one
+	assert.False(t, s.Hasher.NeedsRehash(s.dummyHash))
+	m.redis.AssertCalled(t, "RecordLoginFailure", ctx, "email:nobody@example.com", mock.Anything)
+}
+
+func TestDeleteUser(t *testing.T) {
+	now := time.Date(2024, 11, 22, 9, 30, 0, 0, time.UTC)
+	fixedNow(t, now)
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.users.On("FindByID", ctx, testUserID).Return(testUser(t, s, "correct horse"), nil)
+	m.roles.On("FindByName", ctx, "User").Return(&model.Role{Name: "User"}, nil)
+	m.users.On("Delete", ctx, testUserID).Return(nil)
+	// a deleted user can't go on with the tokens it has
+	m.redis.On("RevokeUserTokens", ctx, testUserID, now, time.Hour).Return(nil)
+
+	assert.NoError(t, s.DeleteUser(ctx, testUserID))
+}
+
+func TestRestoreUser(t *testing.T) {
+	s, m := newTestUserService(t)
+	ctx := context.Background()
+	m.users.On("FindDeletedByID", ctx, testUserID).Return(nil, repository.ErrNotFound)
+
+	// purged or never deleted
+	_, err := s.RestoreUser(ctx, testUserID)
+	assert.Equal(t, apperror.CodeNotFound, apperror.CodeOf(err))
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/apperror"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/model"
+	"go-graphql-user-svc/internal/repository"
+	"go-graphql-user-svc/util"
+	"log"
+	"net/url"
+	"time"
+)
+
+const verifyTokenBytes = 32
+
+var errInvalidVerifyToken = apperror.Validation("verification token is invalid or expired").WithDetail("field", "token")
+
+// SendVerificationEmail sends a new verification link to an unverified account.
+// It never tells whether the email belongs to an account.
+func (s *UserService) SendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.Repo.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	// unknown emails succeed without a mail, so a failed send must look the same
+	if err := s.sendVerification(ctx, user); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// VerifyEmail marks the email the token was sent to as verified and activates a pending account
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	v, err := s.RedisRepo.ConsumeEmailVerificationToken(ctx, util.HashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return errInvalidVerifyToken
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	err = s.Repo.MarkEmailVerified(ctx, v.UserID, v.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		// the user is gone or changed the email since the link was sent
+		return errInvalidVerifyToken
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+func (s *UserService) sendVerification(ctx context.Context, user *model.User) error {
+	token, err := util.GenerateRandomToken(verifyTokenBytes)
+	if err != nil {
+		return err
+	}
+	v := model.EmailVerification{UserID: string(user.ID), Email: user.Email}
+	ttl := s.Cfg.EmailVerifyConfig.TTL * time.Second
+	if err := s.RedisRepo.StoreEmailVerificationToken(ctx, util.HashToken(token), v, ttl); err != nil {
+		return err
+	}
+	link := s.Cfg.EmailVerifyConfig.URL + "?" + url.Values{"token": {token}}.Encode()
+	return s.Mailer.Send(ctx, user.Email, mailer.TemplateEmailVerification, mailer.EmailVerificationData{
+		Name:       user.Name,
+		URL:        link,
+		ValidHours: int(s.Cfg.EmailVerifyConfig.TTL / 3600),
+	})
+}
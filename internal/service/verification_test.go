@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-graphql-user-svc/internal/mailer"
+	"go-graphql-user-svc/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendVerificationEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		known    bool
+		verified bool
+		sendErr  error
+	}{
+		{name: "unverified account", known: true},
+		{name: "failed send", known: true, sendErr: errors.New("connection refused")},
+		{name: "verified account", known: true, verified: true},
+		{name: "unknown email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newTestUserService(t)
+			ctx := context.Background()
+			user := testUser(t, s, "correct horse")
+			user.EmailVerified = tt.verified
+			if tt.known {
+				m.users.On("FindByEmail", ctx, "ada@example.com").Return(user, nil)
+			} else {
+				m.users.On("FindByEmail", ctx, "ada@example.com").Return(nil, repository.ErrNotFound)
+			}
+			if tt.known && !tt.verified {
+				m.redis.On("StoreEmailVerificationToken", ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.mail.On("Send", ctx, "ada@example.com", mailer.TemplateEmailVerification, mock.Anything).Return(tt.sendErr)
+			}
+
+			// every answer looks the same, whether the email belongs to an account or not
+			assert.NoError(t, s.SendVerificationEmail(ctx, "ada@example.com"))
+		})
+	}
+}
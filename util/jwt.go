@@ -9,22 +9,37 @@ import (
 
 var TokenDuration = time.Hour * 24 * 7
 
+func init() {
+	// Whole seconds can't tell a token issued just before a revocation of all the
+	// user's tokens from the one issued right after it
+	jwt.TimePrecision = time.Microsecond
+}
+
 type Claims struct {
-	jwt.StandardClaims
-	ID   string `json:"id"`
-	Role string `json:"role"`
-	Exp  int64  `json:"exp"`
+	jwt.RegisteredClaims
+	ID       string   `json:"id"`
+	Role     string   `json:"role"`
+	TenantID string   `json:"tid,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// AuthMethods are the RFC 8176 authentication method references
+	AuthMethods []string `json:"amr,omitempty"`
 }
 
-func GenerateToken(c Claims, tokenDuration time.Duration, secretKey string) (string, error) {
+func GenerateToken(c Claims, tokenDuration time.Duration, keys *KeySet) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		ID:   c.ID,
-		Role: c.Role,
-		Exp:  time.Now().Add(tokenDuration * time.Second).Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        c.RegisteredClaims.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenDuration * time.Second)),
+		},
+		ID:          c.ID,
+		Role:        c.Role,
+		TenantID:    c.TenantID,
+		Groups:      c.Groups,
+		AuthMethods: c.AuthMethods,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -32,14 +47,9 @@ func GenerateToken(c Claims, tokenDuration time.Duration, secretKey string) (str
 	return tokenString, nil
 }
 
-func VerifyToken(tokenString string, secretKey string) (*Claims, error) {
+func VerifyToken(tokenString string, keys *KeySet) (*Claims, error) {
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.Keyfunc)
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenIssuedAtPrecision(t *testing.T) {
+	keys := NewHMACKeySet("test")
+	before := time.Now().Truncate(time.Microsecond)
+	token, err := GenerateToken(Claims{ID: "6740a6a9a5a4cf3a1c5d1e01"}, 900, keys)
+	require.NoError(t, err)
+
+	claims, err := VerifyToken(token, keys)
+	require.NoError(t, err)
+	// a revocation earlier in the same second must not cover the token
+	assert.False(t, claims.IssuedAt.Time.Before(before), "iat %v lost the time within the second", claims.IssuedAt.Time)
+	assert.WithinDuration(t, before.Add(900*time.Second), claims.ExpiresAt.Time, time.Second)
+}
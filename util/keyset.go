@@ -0,0 +1,200 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/config"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is one entry of a KeySet. A key without a private part can only verify tokens,
+// which is how a retired key keeps accepting the tokens it signed until they expire.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens are signed with
+type KeySet struct {
+	signingKey *SigningKey
+	keys       map[string]*SigningKey
+}
+
+// NewHMACKeySet returns a key set that signs and verifies with a shared HS256 secret
+func NewHMACKeySet(secretKey string) *KeySet {
+	key := &SigningKey{
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: []byte(secretKey),
+		PublicKey:  []byte(secretKey),
+	}
+	return &KeySet{
+		signingKey: key,
+		keys:       map[string]*SigningKey{"": key},
+	}
+}
+
+// LoadKeySet builds the key set from the auth-token config. Without any configured key
+// it falls back to HS256 with the shared secret.
+func LoadKeySet(cfg config.AuthTokenConfig) (*KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		return NewHMACKeySet(cfg.SecretKey), nil
+	}
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("signing key id is required")
+		}
+		if _, ok := ks.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %q", kc.ID)
+		}
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing key %q: %v", kc.ID, err)
+		}
+		ks.keys[kc.ID] = key
+	}
+
+	signingKey, ok := ks.keys[cfg.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", cfg.SigningKeyID)
+	}
+	if signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", cfg.SigningKeyID)
+	}
+	ks.signingKey = signingKey
+	return ks, nil
+}
+
+func loadSigningKey(kc config.SigningKeyConfig) (*SigningKey, error) {
+	key := &SigningKey{ID: kc.ID}
+	switch kc.Algorithm {
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+	case "ES256":
+		key.Method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.Method == jwt.SigningMethodRS256 {
+			pk, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.PrivateKey, key.PublicKey = pk, &pk.PublicKey
+		} else {
+			pk, err := jwt.ParseECPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.PrivateKey, key.PublicKey = pk, &pk.PublicKey
+		}
+	} else if kc.PublicKeyFile != "" {
+		pem, err := os.ReadFile(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.Method == jwt.SigningMethodRS256 {
+			key.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		} else {
+			key.PublicKey, err = jwt.ParseECPublicKeyFromPEM(pem)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("either private-key-file or public-key-file is required")
+	}
+
+	if pub, ok := key.PublicKey.(*ecdsa.PublicKey); ok && pub.Curve != elliptic.P256() {
+		return nil, errors.New("ES256 requires a P-256 key")
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the current signing key and sets its kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingKey.Method, claims)
+	if ks.signingKey.ID != "" {
+		token.Header["kid"] = ks.signingKey.ID
+	}
+	return token.SignedString(ks.signingKey.PrivateKey)
+}
+
+// Keyfunc picks the verification key by the token's kid and refuses any other algorithm
+// than the one the key was configured with
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.PublicKey, nil
+}
+
+// JWK is the public part of a signing key as published in a JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Shared HMAC secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "EC",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: pub.Curve.Params().Name,
+				X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+				Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
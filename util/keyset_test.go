@@ -0,0 +1,156 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"go-graphql-user-svc/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys writes the private key and its public key as PEM files and returns their paths
+func writeKeys(t *testing.T, name string, key crypto.Signer) (string, string) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	dir := t.TempDir()
+	private := filepath.Join(dir, name+".pem")
+	public := filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(private, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600))
+	return private, public
+}
+
+func TestKeySetRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newPrivate, _ := writeKeys(t, "new", rsaKey)
+	oldPrivate, oldPublic := writeKeys(t, "old", ecKey)
+
+	// before the rotation "old" signs
+	before, err := LoadKeySet(config.AuthTokenConfig{
+		SigningKeyID: "old",
+		Keys:         []config.SigningKeyConfig{{ID: "old", Algorithm: "ES256", PrivateKeyFile: oldPrivate}},
+	})
+	require.NoError(t, err)
+	oldToken, err := GenerateToken(Claims{ID: "user"}, 900, before)
+	require.NoError(t, err)
+
+	// after it "old" only verifies
+	after, err := LoadKeySet(config.AuthTokenConfig{
+		SigningKeyID: "new",
+		Keys: []config.SigningKeyConfig{
+			{ID: "new", Algorithm: "RS256", PrivateKeyFile: newPrivate},
+			{ID: "old", Algorithm: "ES256", PublicKeyFile: oldPublic},
+		},
+	})
+	require.NoError(t, err)
+	newToken, err := GenerateToken(Claims{ID: "user"}, 900, after)
+	require.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		claims, err := VerifyToken(token, after)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.ID)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+	_, err = VerifyToken(newToken, before)
+	assert.Error(t, err, "a key set without the new key can't verify its tokens")
+
+	jwks := after.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, JWK{Kty: "RSA", Kid: "new", Use: "sig", Alg: "RS256", N: jwks.Keys[0].N, E: "AQAB"}, jwks.Keys[0])
+	assert.Equal(t, "EC", jwks.Keys[1].Kty)
+	assert.Equal(t, "old", jwks.Keys[1].Kid)
+	assert.Equal(t, "P-256", jwks.Keys[1].Crv)
+	assert.Len(t, jwks.Keys[1].X, 43, "coordinates are padded to the curve size")
+	assert.Len(t, jwks.Keys[1].Y, 43)
+}
+
+func TestKeySetRefusesAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	private, public := writeKeys(t, "rsa", rsaKey)
+	keys, err := LoadKeySet(config.AuthTokenConfig{
+		SigningKeyID: "rsa",
+		Keys:         []config.SigningKeyConfig{{ID: "rsa", Algorithm: "RS256", PrivateKeyFile: private}},
+	})
+	require.NoError(t, err)
+
+	// an HS256 token keyed with the published public key
+	pubPEM, err := os.ReadFile(public)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{ID: "admin"})
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString(pubPEM)
+	require.NoError(t, err)
+	_, err = VerifyToken(token, keys)
+	assert.Error(t, err)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, Claims{ID: "admin"})
+	unknown.Header["kid"] = "other"
+	token, err = unknown.SignedString(rsaKey)
+	require.NoError(t, err)
+	_, err = VerifyToken(token, keys)
+	assert.Error(t, err)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	private, public := writeKeys(t, "ec", ecKey)
+	p384Private, _ := writeKeys(t, "p384", p384Key)
+
+	tests := []struct {
+		name    string
+		cfg     config.AuthTokenConfig
+		wantErr bool
+	}{
+		{name: "shared secret", cfg: config.AuthTokenConfig{SecretKey: "secret"}},
+		{name: "signing key", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256", PrivateKeyFile: private}}}},
+		{name: "signing key not configured", cfg: config.AuthTokenConfig{SigningKeyID: "other", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256", PrivateKeyFile: private}}}, wantErr: true},
+		{name: "signing key without private key", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256", PublicKeyFile: public}}}, wantErr: true},
+		{name: "duplicate id", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256", PrivateKeyFile: private}, {ID: "ec", Algorithm: "ES256", PublicKeyFile: public}}}, wantErr: true},
+		{name: "no id", cfg: config.AuthTokenConfig{Keys: []config.SigningKeyConfig{{Algorithm: "ES256", PrivateKeyFile: private}}}, wantErr: true},
+		{name: "unsupported algorithm", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "HS256", PrivateKeyFile: private}}}, wantErr: true},
+		{name: "ES256 on another curve", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256", PrivateKeyFile: p384Private}}}, wantErr: true},
+		{name: "no key file", cfg: config.AuthTokenConfig{SigningKeyID: "ec", Keys: []config.SigningKeyConfig{{ID: "ec", Algorithm: "ES256"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeySet(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			token, err := GenerateToken(Claims{ID: "user"}, 900, keys)
+			require.NoError(t, err)
+			_, err = VerifyToken(token, keys)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestJWKSLeavesOutSharedSecrets(t *testing.T) {
+	assert.Empty(t, NewHMACKeySet("secret").JWKS().Keys)
+}
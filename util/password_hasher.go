@@ -0,0 +1,202 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-graphql-user-svc/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordMismatch = errors.New("password does not match")
+	ErrUnknownHash      = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes passwords into self-describing strings and checks them
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) error
+	// NeedsRehash reports whether the hash should be replaced by a fresh one
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher produces PHC strings like $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func NewArgon2idHasher(cfg config.Argon2Config) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      cfg.Memory,
+		Iterations:  cfg.Iterations,
+		Parallelism: cfg.Parallelism,
+		SaltLength:  cfg.SaltLength,
+		KeyLength:   cfg.KeyLength,
+	}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password string, encoded string) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// NeedsRehash is true for any other format and for argon2id hashes made with other parameters
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Memory || params.Iterations != h.Iterations || params.Parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength || uint32(len(key)) != h.KeyLength
+}
+
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id hash: %v", err)
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id hash: %v", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+	if len(key) == 0 {
+		return nil, nil, nil, errors.New("invalid argon2id key: empty")
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher produces modular crypt strings like $2a$12$<salt><key>
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cfg config.BcryptConfig) *BcryptHasher {
+	return &BcryptHasher{Cost: cfg.Cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (h *BcryptHasher) Verify(password string, encoded string) error {
+	if !isBcryptHash(encoded) {
+		return ErrUnknownHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+// NeedsRehash is true for any other format and for bcrypt hashes with a lower cost
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// passwordHashers hashes with the configured algorithm and verifies every supported one,
+// so hashes stored before a switch keep working until they are upgraded
+type passwordHashers struct {
+	preferred PasswordHasher
+	argon2id  *Argon2idHasher
+	bcrypt    *BcryptHasher
+}
+
+// NewPasswordHasher builds the hasher for the password-hash config
+func NewPasswordHasher(cfg config.PasswordHashConfig) (PasswordHasher, error) {
+	h := &passwordHashers{
+		argon2id: NewArgon2idHasher(cfg.Argon2id),
+		bcrypt:   NewBcryptHasher(cfg.Bcrypt),
+	}
+	switch cfg.Algorithm {
+	case "argon2id":
+		if h.argon2id.Memory == 0 || h.argon2id.Iterations == 0 || h.argon2id.Parallelism == 0 ||
+			h.argon2id.SaltLength < 8 || h.argon2id.KeyLength < 16 {
+			return nil, errors.New("argon2id needs memory, iterations and parallelism, a salt of at least 8 and a key of at least 16 bytes")
+		}
+		h.preferred = h.argon2id
+	case "bcrypt":
+		if h.bcrypt.Cost < bcrypt.MinCost || h.bcrypt.Cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		h.preferred = h.bcrypt
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+	return h, nil
+}
+
+func (h *passwordHashers) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *passwordHashers) Verify(password string, encoded string) error {
+	if isBcryptHash(encoded) {
+		return h.bcrypt.Verify(password, encoded)
+	}
+	return h.argon2id.Verify(password, encoded)
+}
+
+func (h *passwordHashers) NeedsRehash(encoded string) bool {
+	return h.preferred.NeedsRehash(encoded)
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"go-graphql-user-svc/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cheap parameters, the tests are about the formats and not about the cost
+var (
+	testArgon2 = config.Argon2Config{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	testBcrypt = config.BcryptConfig{Cost: 4}
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PasswordHashConfig
+		wantErr bool
+	}{
+		{name: "argon2id", cfg: config.PasswordHashConfig{Algorithm: "argon2id", Argon2id: testArgon2}},
+		{name: "bcrypt", cfg: config.PasswordHashConfig{Algorithm: "bcrypt", Bcrypt: testBcrypt}},
+		{name: "unknown algorithm", cfg: config.PasswordHashConfig{Algorithm: "md5"}, wantErr: true},
+		{name: "argon2id without memory", cfg: config.PasswordHashConfig{Algorithm: "argon2id", Argon2id: config.Argon2Config{Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}, wantErr: true},
+		{name: "argon2id short salt", cfg: config.PasswordHashConfig{Algorithm: "argon2id", Argon2id: config.Argon2Config{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}}, wantErr: true},
+		{name: "bcrypt cost too low", cfg: config.PasswordHashConfig{Algorithm: "bcrypt", Bcrypt: config.BcryptConfig{Cost: 2}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPasswordHasher(tt.cfg)
+			assert.Equal(t, tt.wantErr, err != nil, "error: %v", err)
+		})
+	}
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	argon, err := NewPasswordHasher(config.PasswordHashConfig{Algorithm: "argon2id", Argon2id: testArgon2, Bcrypt: testBcrypt})
+	require.NoError(t, err)
+	bc, err := NewPasswordHasher(config.PasswordHashConfig{Algorithm: "bcrypt", Argon2id: testArgon2, Bcrypt: testBcrypt})
+	require.NoError(t, err)
+
+	argonHash, err := argon.Hash("correct horse")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=64,t=1,p=1$"), argonHash)
+	bcryptHash, err := bc.Hash("correct horse")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(bcryptHash, "$2a$04$"), bcryptHash)
+
+	tests := []struct {
+		name     string
+		hasher   PasswordHasher
+		password string
+		encoded  string
+		want     error
+	}{
+		{name: "argon2id", hasher: argon, password: "correct horse", encoded: argonHash},
+		{name: "argon2id mismatch", hasher: argon, password: "battery staple", encoded: argonHash, want: ErrPasswordMismatch},
+		{name: "bcrypt", hasher: bc, password: "correct horse", encoded: bcryptHash},
+		{name: "bcrypt mismatch", hasher: bc, password: "battery staple", encoded: bcryptHash, want: ErrPasswordMismatch},
+		// hashes stored before the algorithm changed keep working
+		{name: "bcrypt hash with argon2id preferred", hasher: argon, password: "correct horse", encoded: bcryptHash},
+		{name: "argon2id hash with bcrypt preferred", hasher: bc, password: "correct horse", encoded: argonHash},
+		{name: "unknown format", hasher: argon, password: "correct horse", encoded: "5f4dcc3b5aa765d61d8327deb882cf99", want: ErrUnknownHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.hasher.Verify(tt.password, tt.encoded), tt.want)
+		})
+	}
+
+	t.Run("salted", func(t *testing.T) {
+		again, err := argon.Hash("correct horse")
+		require.NoError(t, err)
+		assert.NotEqual(t, argonHash, again)
+	})
+	t.Run("empty password", func(t *testing.T) {
+		_, err := argon.Hash("")
+		assert.Error(t, err)
+		_, err = bc.Hash("")
+		assert.Error(t, err)
+	})
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	hash := func(h PasswordHasher) string {
+		encoded, err := h.Hash("correct horse")
+		require.NoError(t, err)
+		return encoded
+	}
+	smallArgon := NewArgon2idHasher(config.Argon2Config{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	argon := NewArgon2idHasher(testArgon2)
+	bc := NewBcryptHasher(testBcrypt)
+	strongerBcrypt := NewBcryptHasher(config.BcryptConfig{Cost: 5})
+
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{name: "argon2id current", hasher: argon, encoded: hash(argon)},
+		{name: "argon2id other memory", hasher: argon, encoded: hash(smallArgon), want: true},
+		{name: "argon2id over bcrypt", hasher: argon, encoded: hash(bc), want: true},
+		{name: "bcrypt current", hasher: bc, encoded: hash(bc)},
+		{name: "bcrypt lower cost", hasher: strongerBcrypt, encoded: hash(bc), want: true},
+		{name: "bcrypt higher cost", hasher: bc, encoded: hash(strongerBcrypt)},
+		{name: "bcrypt over argon2id", hasher: bc, encoded: hash(argon), want: true},
+		{name: "garbage", hasher: argon, encoded: "$argon2id$v=19$m=64$x$y", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.hasher.NeedsRehash(tt.encoded))
+		})
+	}
+}
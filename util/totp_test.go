@@ -0,0 +1,102 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA-1 secret of RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// the last six digits of the RFC 6238 test vectors
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "time %d", tt.unix)
+	}
+
+	lower, err := TOTPCode(strings.ToLower(rfcSecret), 1)
+	require.NoError(t, err)
+	upper, err := TOTPCode(rfcSecret, 1)
+	require.NoError(t, err)
+	assert.Equal(t, upper, lower, "the secret is case insensitive")
+
+	_, err = TOTPCode("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfcSecret, step)
+		require.NoError(t, err)
+		return c
+	}
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: code(step), wantStep: step, wantOK: true},
+		{name: "previous step", secret: rfcSecret, code: code(step - 1), wantStep: step - 1, wantOK: true},
+		{name: "next step", secret: rfcSecret, code: code(step + 1), wantStep: step + 1, wantOK: true},
+		{name: "two steps ago", secret: rfcSecret, code: code(step - 2)},
+		{name: "spaces", secret: rfcSecret, code: " " + code(step)[:3] + " " + code(step)[3:] + " ", wantStep: step, wantOK: true},
+		{name: "too short", secret: rfcSecret, code: code(step)[:5]},
+		{name: "too long", secret: rfcSecret, code: code(step) + "0"},
+		{name: "empty", secret: rfcSecret, code: ""},
+		{name: "bad secret", secret: "not base32!", code: "123456"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantStep, gotStep)
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	key, err := totpEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, totpSecretSize)
+
+	other, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("User Service", "ada@example.com", rfcSecret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/User Service:ada@example.com", uri.Path)
+	q := uri.Query()
+	assert.Equal(t, rfcSecret, q.Get("secret"))
+	assert.Equal(t, "User Service", q.Get("issuer"))
+	assert.Equal(t, "6", q.Get("digits"))
+	assert.Equal(t, "30", q.Get("period"))
+}